@@ -0,0 +1,67 @@
+package fail
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const hexRowLen = 16
+
+// IfBytesNotEqual panics if got and want are not equal, constructing a failure message with a hex dump of both
+// slices around the first differing offset and args. The differing byte is shown in brackets. It must be used in
+// conjunction with Using.
+func IfBytesNotEqual(got, want []byte, args ...interface{}) {
+	if !bytes.Equal(got, want) {
+		Now(append([]interface{}{bytesDiff(got, want, 0)}, args...)...)
+	}
+}
+
+// IfEncodeNotEqual encodes v with enc and panics if enc returns an error or the encoded bytes are not equal to want,
+// constructing a failure message like that of IfBytesNotEqual, and args. Unlike IfNotRoundTrip, it pins the exact
+// output of enc, for testing wire formats. It must be used in conjunction with Using.
+func IfEncodeNotEqual[T any](v T, enc func(T) ([]byte, error), want []byte, args ...interface{}) {
+	got, err := enc(v)
+	if err != nil {
+		Now(append([]interface{}{"encode failed:", renderErr(err)}, args...)...)
+		return
+	}
+	if !bytes.Equal(got, want) {
+		Now(append([]interface{}{fmt.Sprintf("encoding of %#v: %s", v, bytesDiff(got, want, 0))}, args...)...)
+	}
+}
+
+// bytesDiff renders a hex dump of got and want around the first offset at which they differ. base is added to
+// the offsets displayed, for slices that are part of a larger stream.
+func bytesDiff(got, want []byte, base int) string {
+	at := 0
+	for at < len(got) && at < len(want) && got[at] == want[at] {
+		at++
+	}
+	start := at/hexRowLen*hexRowLen - hexRowLen
+	if start < 0 {
+		start = 0
+	}
+	end := at/hexRowLen*hexRowLen + 2*hexRowLen
+	return fmt.Sprintf("bytes differ at offset %d (0x%x)\ngot (len %d):\n%swant (len %d):\n%s",
+		base+at, base+at, len(got), hexRows(got, start, end, at, base), len(want), hexRows(want, start, end, at, base))
+}
+
+func hexRows(b []byte, start, end, mark, base int) string {
+	var buf strings.Builder
+	for off := start; off < end && off < len(b); off += hexRowLen {
+		fmt.Fprintf(&buf, "%08x ", base+off)
+		for i := off; i < off+hexRowLen && i < len(b); i++ {
+			if i == mark {
+				fmt.Fprintf(&buf, "[%02x]", b[i])
+			} else {
+				fmt.Fprintf(&buf, " %02x ", b[i])
+			}
+		}
+		buf.WriteString("\n")
+	}
+	if mark >= len(b) {
+		fmt.Fprintf(&buf, "%08x <end>\n", base+len(b))
+	}
+	return buf.String()
+}
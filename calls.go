@@ -0,0 +1,86 @@
+package fail
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ExpectCalls returns a callback to pass to the code under test and a check function that panics if the callback
+// has not been called exactly n times, constructing a failure message with the actual and expected counts. The
+// callback is safe for concurrent use. check must be used in conjunction with Using and is typically deferred.
+//
+//	cb, check := fail.ExpectCalls(2)
+//	defer check()
+//	RunWithCallback(cb)
+func ExpectCalls(n int) (cb func(), check func()) {
+	var mu sync.Mutex
+	calls := 0
+	cb = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+	}
+	check = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if calls != n {
+			Now(fmt.Sprintf("callback called %d times, want %d", calls, n))
+		}
+	}
+	return cb, check
+}
+
+// ExpectCallsOf is like ExpectCalls, but returns a callback accepting an argument. The arguments received are
+// included in the failure message.
+func ExpectCallsOf[T any](n int) (cb func(T), check func()) {
+	var mu sync.Mutex
+	var calls []T
+	cb = func(arg T) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, arg)
+	}
+	check = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(calls) != n {
+			Now(fmt.Sprintf("callback called %d times, want %d, arguments: %v", len(calls), n, calls))
+		}
+	}
+	return cb, check
+}
+
+// OrderTracker returns a record function for the code under test to call, for example from cleanup functions, and
+// an assert function that panics if the names recorded are not exactly expected, in order, constructing a failure
+// message with the recorded and expected sequences. It is equivalent to RecordArgs[string]. record is safe for
+// concurrent use. assert must be used in conjunction with Using.
+func OrderTracker() (record func(name string), assert func(expected ...string)) {
+	return RecordArgs[string]()
+}
+
+// RecordArgs returns a callback for the code under test that records each argument it receives and an assert
+// function that panics if the recorded arguments are not reflect.DeepEqual to want, in order, constructing a failure
+// message with the recorded and expected sequences. The callback is safe for concurrent use. assert must be used in
+// conjunction with Using and is typically deferred.
+//
+//	cb, assert := fail.RecordArgs[int]()
+//	defer assert(1, 2, 3)
+//	Walk(tree, cb)
+func RecordArgs[T any]() (cb func(T), assert func(want ...T)) {
+	var mu sync.Mutex
+	var recorded []T
+	cb = func(arg T) {
+		mu.Lock()
+		defer mu.Unlock()
+		recorded = append(recorded, arg)
+	}
+	assert = func(want ...T) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !reflect.DeepEqual(recorded, want) && (len(recorded) > 0 || len(want) > 0) {
+			Now(fmt.Sprintf("recorded arguments %#v, want %#v", recorded, want))
+		}
+	}
+	return cb, assert
+}
@@ -0,0 +1,36 @@
+package fail
+
+import "fmt"
+
+// IfNotClosed panics if ch is not closed, constructing a failure message stating whether a value was pending or the
+// receive would have blocked, and args. It does not block. If a value is pending it is received and included in the
+// message. It must be used in conjunction with Using.
+func IfNotClosed[T any](ch <-chan T, args ...interface{}) {
+	select {
+	case v, ok := <-ch:
+		if ok {
+			Now(append([]interface{}{fmt.Sprintf("channel is not closed, received pending value %#v", v)}, args...)...)
+		}
+	default:
+		Now(append([]interface{}{"channel is not closed, receive would block"}, args...)...)
+	}
+}
+
+// IfChanLen panics if the number of elements queued in ch is not want, constructing a failure message with the
+// actual and expected lengths and args. It must be used in conjunction with Using.
+//
+// The length of a channel that is used by other goroutines can change at any time, so the check is only reliable
+// when no other goroutine is sending to or receiving from ch.
+func IfChanLen[T any](ch chan T, want int, args ...interface{}) {
+	if n := len(ch); n != want {
+		Now(append([]interface{}{fmt.Sprintf("channel length %d != %d", n, want)}, args...)...)
+	}
+}
+
+// IfChanCap panics if the capacity of ch is not want, constructing a failure message with the actual and expected
+// capacities and args. It must be used in conjunction with Using.
+func IfChanCap[T any](ch chan T, want int, args ...interface{}) {
+	if n := cap(ch); n != want {
+		Now(append([]interface{}{fmt.Sprintf("channel capacity %d != %d", n, want)}, args...)...)
+	}
+}
@@ -0,0 +1,224 @@
+package fail
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// IfNotContainsAll panics if any of the wanted elements are not present in haystack, constructing a failure message
+// listing the missing elements and args. It must be used in conjunction with Using.
+func IfNotContainsAll[T comparable](haystack []T, wanted []T, args ...interface{}) {
+	present := make(map[T]bool, len(haystack))
+	for _, v := range haystack {
+		present[v] = true
+	}
+	var missing []T
+	for _, w := range wanted {
+		if !present[w] {
+			missing = append(missing, w)
+		}
+	}
+	if len(missing) > 0 {
+		Now(append([]interface{}{fmt.Sprintf("%v does not contain %v", haystack, missing)}, args...)...)
+	}
+}
+
+// IfNotContainsAny panics if none of the options are present in haystack, constructing a failure message listing
+// the options searched for and args. It must be used in conjunction with Using.
+func IfNotContainsAny[T comparable](haystack []T, options []T, args ...interface{}) {
+	for _, v := range haystack {
+		for _, o := range options {
+			if v == o {
+				return
+			}
+		}
+	}
+	Now(append([]interface{}{fmt.Sprintf("%v does not contain any of %v", haystack, options)}, args...)...)
+}
+
+// IfDuplicates panics if s contains any element more than once, constructing a failure message with the first
+// duplicated element, the indices at which it occurs and args. It must be used in conjunction with Using.
+func IfDuplicates[T comparable](s []T, args ...interface{}) {
+	IfDuplicatesBy(s, func(v T) T { return v }, args...)
+}
+
+// IfDuplicatesBy panics if key returns the same value for more than one element of s, constructing a failure
+// message with the first duplicated key, the indices of the elements with that key and args. It must be used in
+// conjunction with Using.
+func IfDuplicatesBy[T any, K comparable](s []T, key func(T) K, args ...interface{}) {
+	seen := make(map[K]bool, len(s))
+	for _, v := range s {
+		k := key(v)
+		if !seen[k] {
+			seen[k] = true
+			continue
+		}
+		var at []int
+		for i, w := range s {
+			if key(w) == k {
+				at = append(at, i)
+			}
+		}
+		Now(append([]interface{}{fmt.Sprintf("%v is duplicated at indices %v", k, at)}, args...)...)
+		return
+	}
+}
+
+// IfNotSubset panics if any key in wantSubset is missing from got or has a value in got that is not
+// reflect.DeepEqual to its value in wantSubset, constructing a failure message listing the offending keys and args.
+// Keys in got that are not in wantSubset are ignored. It must be used in conjunction with Using.
+func IfNotSubset[K comparable, V any](got, wantSubset map[K]V, args ...interface{}) {
+	var diffs []string
+	for k, want := range wantSubset {
+		v, ok := got[k]
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("%v: missing, want %#v", k, want))
+		case !reflect.DeepEqual(v, want):
+			diffs = append(diffs, fmt.Sprintf("%v: %#v != %#v", k, v, want))
+		}
+	}
+	if len(diffs) > 0 {
+		sort.Strings(diffs)
+		Now(append([]interface{}{"not a subset:\n" + strings.Join(diffs, "\n")}, args...)...)
+	}
+}
+
+// IfNotSubsetSlice panics if got does not contain every element of wantSubset, constructing a failure message listing
+// the missing elements and args. An element that occurs more than once in wantSubset must occur at least as many times
+// in got. It must be used in conjunction with Using.
+func IfNotSubsetSlice[T comparable](got, wantSubset []T, args ...interface{}) {
+	counts := make(map[T]int, len(got))
+	for _, v := range got {
+		counts[v]++
+	}
+	var missing []T
+	for _, w := range wantSubset {
+		if counts[w] == 0 {
+			missing = append(missing, w)
+			continue
+		}
+		counts[w]--
+	}
+	if len(missing) > 0 {
+		Now(append([]interface{}{fmt.Sprintf("%v is not a subset of %v, missing %v", wantSubset, got, missing)}, args...)...)
+	}
+}
+
+// IfNotPermutation panics if got is not a reordering of want, constructing a failure message listing the elements
+// that are missing from or extra in got, with their counts, and args. Duplicate elements must occur the same number
+// of times in both slices. It must be used in conjunction with Using.
+func IfNotPermutation[T comparable](got, want []T, args ...interface{}) {
+	IfNotPermutationBy(got, want, func(v T) T { return v }, args...)
+}
+
+// IfNotPermutationBy is like IfNotPermutation, but compares elements by the value returned by key.
+func IfNotPermutationBy[T any, K comparable](got, want []T, key func(T) K, args ...interface{}) {
+	if diffs := permutationDiff(got, want, key); len(diffs) > 0 {
+		Now(append([]interface{}{fmt.Sprintf("%v is not a permutation of %v: %s", got, want, strings.Join(diffs, ", "))}, args...)...)
+	}
+}
+
+// permutationDiff lists the elements, compared by key, that are extra in or missing from got, with their counts, in
+// the order they are first seen.
+func permutationDiff[T any, K comparable](got, want []T, key func(T) K) []string {
+	counts := map[K]int{}
+	var order []K
+	count := func(s []T, delta int) {
+		for _, v := range s {
+			k := key(v)
+			if _, ok := counts[k]; !ok {
+				order = append(order, k)
+			}
+			counts[k] += delta
+		}
+	}
+	count(got, 1)
+	count(want, -1)
+	var diffs []string
+	for _, k := range order {
+		switch n := counts[k]; {
+		case n > 0:
+			diffs = append(diffs, fmt.Sprintf("extra %v (x%d)", k, n))
+		case n < 0:
+			diffs = append(diffs, fmt.Sprintf("missing %v (x%d)", k, -n))
+		}
+	}
+	return diffs
+}
+
+// IfGroupNotEqual panics if got and want do not have the same keys or, for any key, the slices in got and want are not
+// permutations of each other, constructing a failure message listing each differing key with its missing or extra
+// elements, and args. It is useful for grouping code in which the order of each group is not deterministic. It must
+// be used in conjunction with Using.
+func IfGroupNotEqual[K comparable, V comparable](got, want map[K][]V, args ...interface{}) {
+	var diffs []string
+	for k, w := range want {
+		g, ok := got[k]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("%v: missing, want %v", k, w))
+			continue
+		}
+		if d := permutationDiff(g, w, func(v V) V { return v }); len(d) > 0 {
+			diffs = append(diffs, fmt.Sprintf("%v: %s", k, strings.Join(d, ", ")))
+		}
+	}
+	for k, g := range got {
+		if _, ok := want[k]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%v: unexpected %v", k, g))
+		}
+	}
+	if len(diffs) > 0 {
+		sort.Strings(diffs)
+		Now(append([]interface{}{"groups differ:\n" + strings.Join(diffs, "\n")}, args...)...)
+	}
+}
+
+// IfNotOneOf panics if got is not one of allowed, constructing a failure message with got, the allowed values and
+// args. It must be used in conjunction with Using.
+func IfNotOneOf[T comparable](got T, allowed []T, args ...interface{}) {
+	for _, a := range allowed {
+		if got == a {
+			return
+		}
+	}
+	Now(append([]interface{}{fmt.Sprintf("%v is not one of %v", got, allowed)}, args...)...)
+}
+
+// All runs check on every element of items and panics if any check fails, constructing a failure message listing
+// the index and error of every failing element, and args. If SetMaxFailures has been called, at most that many
+// failing elements are listed and the rest are counted as suppressed. It must be used in conjunction with Using.
+func All[T any](items []T, check func(T) error, args ...interface{}) {
+	var failures []string
+	failed := 0
+	for i, item := range items {
+		if err := check(item); err != nil {
+			failed++
+			if maxFailures <= 0 || len(failures) < maxFailures {
+				failures = append(failures, fmt.Sprintf("[%d]: %v", i, renderErr(err)))
+			}
+		}
+	}
+	if failed == 0 {
+		return
+	}
+	if n := failed - len(failures); n > 0 {
+		failures = append(failures, fmt.Sprintf("+%d more suppressed", n))
+	}
+	msg := fmt.Sprintf("%d of %d elements failed:\n%s", failed, len(items), strings.Join(failures, "\n"))
+	Now(append([]interface{}{msg}, args...)...)
+}
+
+// IfMapValue panics if key is absent from m or its value is not reflect.DeepEqual to want, constructing a failure
+// message that distinguishes the two cases, and args. It must be used in conjunction with Using.
+func IfMapValue[K comparable, V any](m map[K]V, key K, want V, args ...interface{}) {
+	got, ok := m[key]
+	switch {
+	case !ok:
+		Now(append([]interface{}{fmt.Sprintf("key %#v absent, want value %#v", key, want)}, args...)...)
+	case !reflect.DeepEqual(got, want):
+		Now(append([]interface{}{fmt.Sprintf("key %#v: %#v != %#v", key, got, want)}, args...)...)
+	}
+}
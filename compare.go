@@ -0,0 +1,55 @@
+package fail
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+)
+
+type comparisonRow struct {
+	label     string
+	got, want interface{}
+}
+
+// Comparison collects several independent comparisons so that they can be checked together by IfAnyNotEqual.
+type Comparison struct {
+	rows []comparisonRow
+}
+
+// Compare returns an empty Comparison. Sample usage is below:
+//
+//	fail.Compare().
+//		Add("name", got.Name, "alice").
+//		Add("age", got.Age, 30).
+//		IfAnyNotEqual("unexpected user")
+func Compare() *Comparison {
+	return &Comparison{}
+}
+
+// Add adds a comparison of got and want, identified by label, and returns c.
+func (c *Comparison) Add(label string, got, want interface{}) *Comparison {
+	c.rows = append(c.rows, comparisonRow{label: label, got: got, want: want})
+	return c
+}
+
+// IfAnyNotEqual panics if got is not reflect.DeepEqual to want for any of the comparisons in c, constructing a failure
+// message with an aligned table of the mismatching comparisons and args. It must be used in conjunction with Using.
+func (c *Comparison) IfAnyNotEqual(args ...interface{}) {
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\t\tgot\twant")
+	mismatched := 0
+	for _, r := range c.rows {
+		if !reflect.DeepEqual(r.got, r.want) {
+			fmt.Fprintf(w, "\t%s\t%#v\t%#v\n", r.label, r.got, r.want)
+			mismatched++
+		}
+	}
+	if mismatched == 0 {
+		return
+	}
+	w.Flush()
+	msg := fmt.Sprintf("%d of %d comparisons failed:\n%s", mismatched, len(c.rows), buf.String())
+	Now(append([]interface{}{msg}, args...)...)
+}
@@ -0,0 +1,48 @@
+package fail
+
+import "time"
+
+// config holds the package level configuration changed by the Set and Register functions.
+type config struct {
+	renderers        []errorRenderer
+	extractors       []func(error) (string, bool)
+	includeBuildInfo bool
+	repanicWithStack bool
+	maxFailures      int
+	dryRun           bool
+	logBufferSize    int
+	maxRenderDepth   int
+	repeatAggregate  bool
+	observer         func(string, time.Duration)
+}
+
+// SaveConfig records the current package level configuration, as changed by functions such as SetDryRun,
+// SetMaxFailures and RegisterErrorRenderer, and returns a function that restores it. It keeps configuration changes
+// made by a test from affecting other tests.
+//
+//	func TestSomething(t *testing.T) {
+//		defer fail.SaveConfig()()
+//		fail.SetMaxRenderDepth(1)
+//		...
+//	}
+func SaveConfig() (restore func()) {
+	saved := config{
+		renderers:        append([]errorRenderer(nil), renderers...),
+		extractors:       append([]func(error) (string, bool)(nil), extractors...),
+		includeBuildInfo: includeBuildInfo,
+		repanicWithStack: repanicWithStack,
+		maxFailures:      maxFailures,
+		dryRun:           dryRun,
+		logBufferSize:    logBufferSize,
+		maxRenderDepth:   maxRenderDepth,
+		repeatAggregate:  repeatAggregate,
+		observer:         observer,
+	}
+	return func() {
+		renderers, extractors = saved.renderers, saved.extractors
+		includeBuildInfo, repanicWithStack = saved.includeBuildInfo, saved.repanicWithStack
+		maxFailures, dryRun = saved.maxFailures, saved.dryRun
+		logBufferSize, maxRenderDepth = saved.logBufferSize, saved.maxRenderDepth
+		repeatAggregate, observer = saved.repeatAggregate, saved.observer
+	}
+}
@@ -0,0 +1,64 @@
+package fail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IfErrCtx panics if err is non-nil, constructing a failure message with the error, the deadline of ctx and args.
+// The message notes whether the deadline has been exceeded. If ctx has no deadline it is equivalent to IfErr. It must
+// be used in conjunction with Using.
+func IfErrCtx(ctx context.Context, err error, args ...interface{}) {
+	if err == nil {
+		return
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		Now(append([]interface{}{renderErr(err)}, args...)...)
+		return
+	}
+	at := deadline.Format(time.RFC3339Nano)
+	state := fmt.Sprintf("(deadline %s, %v remaining)", at, time.Until(deadline))
+	if ctx.Err() == context.DeadlineExceeded {
+		state = fmt.Sprintf("(deadline %s exceeded)", at)
+	}
+	Now(append([]interface{}{renderErr(err), state}, args...)...)
+}
+
+// IfNotDone panics if ctx is not done or its error does not match wantErr using errors.Is, constructing a failure
+// message with the actual state of ctx and args. It does not block. It must be used in conjunction with Using.
+func IfNotDone(ctx context.Context, wantErr error, args ...interface{}) {
+	select {
+	case <-ctx.Done():
+		if err := ctx.Err(); !errors.Is(err, wantErr) {
+			Now(append([]interface{}{fmt.Sprintf("context done with %v, want %v", err, wantErr)}, args...)...)
+		}
+	default:
+		Now(append([]interface{}{fmt.Sprintf("context not done, want %v", wantErr)}, args...)...)
+	}
+}
+
+// ContextGrace is the time within which a function passed to IfIgnoresContext must return.
+const ContextGrace = 100 * time.Millisecond
+
+// IfIgnoresContext calls fn with a context that has already been canceled and panics if fn does not return an error
+// matching context.Canceled within ContextGrace, constructing a failure message stating whether fn took too long or
+// returned the wrong error, and args. It must be used in conjunction with Using.
+func IfIgnoresContext(fn func(context.Context) error, args ...interface{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := make(chan error, 1)
+	go func() {
+		result <- fn(ctx)
+	}()
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			Now(append([]interface{}{fmt.Sprintf("returned %v for a canceled context, want %v", err, context.Canceled)}, args...)...)
+		}
+	case <-time.After(ContextGrace):
+		Now(append([]interface{}{fmt.Sprintf("did not return within %v of the context being canceled", ContextGrace)}, args...)...)
+	}
+}
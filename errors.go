@@ -0,0 +1,161 @@
+package fail
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+)
+
+type errorRenderer struct {
+	match  func(error) bool
+	render func(error) string
+}
+
+var renderers []errorRenderer
+
+// RegisterErrorRenderer registers a function to render errors matched by match in the failure messages constructed
+// by IfErr and IfDeferred. Renderers are tried in the order they were registered and the first renderer whose match
+// returns true is used. Errors that no renderer matches are rendered as usual.
+func RegisterErrorRenderer(match func(error) bool, render func(error) string) {
+	renderers = append(renderers, errorRenderer{match: match, render: render})
+}
+
+var extractors []func(error) (string, bool)
+
+// RegisterCodeExtractor registers a function that extracts a status code, such as a gRPC status code, from an
+// error. If an extractor returns true for an error passed to IfErr or IfDeferred, the code is appended to the
+// failure message. Extractors are tried in the order they were registered and the first code found is used.
+func RegisterCodeExtractor(fn func(error) (code string, ok bool)) {
+	extractors = append(extractors, fn)
+}
+
+// renderErr returns the value used to represent err in a failure message. Errors implementing net.Error are
+// annotated with whether they are timeouts or temporary, and errors with a code found by an extractor are annotated
+// with the code.
+func renderErr(err error) interface{} {
+	rendered := render(err)
+	for _, extract := range extractors {
+		if code, ok := extract(err); ok {
+			return fmt.Sprintf("%v (code %s)", rendered, code)
+		}
+	}
+	return rendered
+}
+
+func render(err error) interface{} {
+	for _, r := range renderers {
+		if r.match(err) {
+			return r.render(err)
+		}
+	}
+	var ne net.Error
+	if !errors.As(err, &ne) {
+		return err
+	}
+	msg := err.Error()
+	if ne.Timeout() {
+		msg += " (timeout)"
+	}
+	if ne.Temporary() {
+		msg += " (temporary)"
+	}
+	return msg
+}
+
+// IfJoined panics if any of errs are non-nil, constructing a failure message with each non-nil error on its own
+// line. nil errors are skipped, as with errors.Join. It must be used in conjunction with Using.
+func IfJoined(errs ...error) {
+	joined := errors.Join(errs...)
+	if joined == nil {
+		return
+	}
+	var msg []interface{}
+	for _, err := range joined.(interface{ Unwrap() []error }).Unwrap() {
+		msg = append(msg, renderErr(err), "\n")
+	}
+	Now(msg[:len(msg)-1]...)
+}
+
+// IfErrNotContains panics if err is nil or its message does not contain substr, constructing a failure message with
+// the error, substr and args. It must be used in conjunction with Using.
+func IfErrNotContains(err error, substr string, args ...interface{}) {
+	ifErrNotContains(err, substr, false, args)
+}
+
+// IfErrNotContainsFold is like IfErrNotContains, but ignores case when searching for substr.
+func IfErrNotContainsFold(err error, substr string, args ...interface{}) {
+	ifErrNotContains(err, substr, true, args)
+}
+
+func ifErrNotContains(err error, substr string, fold bool, args []interface{}) {
+	if err == nil {
+		Now(append([]interface{}{fmt.Sprintf("expected an error containing %q, got nil", substr)}, args...)...)
+		return
+	}
+	msg, want := err.Error(), substr
+	if fold {
+		msg, want = strings.ToLower(msg), strings.ToLower(want)
+	}
+	if !strings.Contains(msg, want) {
+		Now(append([]interface{}{fmt.Sprintf("error %q does not contain %q", err.Error(), substr)}, args...)...)
+	}
+}
+
+// Require runs each check in order and panics with the error returned by the first check that fails. It is intended
+// for verifying the preconditions of a test, such as required environment variables or services, at the start of the
+// test. It must be used in conjunction with Using.
+func Require(checks ...func() error) {
+	for i, check := range checks {
+		if err := check(); err != nil {
+			Now(renderErr(err), fmt.Sprintf("(precondition %d)", i))
+			return
+		}
+	}
+}
+
+// RequireAll is like Require, but runs every check and panics with the errors returned by all failing checks.
+func RequireAll(checks ...func() error) {
+	errs := make([]error, len(checks))
+	for i, check := range checks {
+		errs[i] = check()
+	}
+	IfJoined(errs...)
+}
+
+// IfNotAllErr calls fn with each input and panics if any call returns an error that does not match want using
+// errors.Is, constructing a failure message listing each offending input with the error it returned, and args. Calls
+// that return nil are offending. It must be used in conjunction with Using.
+func IfNotAllErr[T any](inputs []T, fn func(T) error, want error, args ...interface{}) {
+	var mismatches []string
+	for i, in := range inputs {
+		switch err := fn(in); {
+		case err == nil:
+			mismatches = append(mismatches, fmt.Sprintf("input %d (%#v): no error", i, in))
+		case !errors.Is(err, want):
+			mismatches = append(mismatches, fmt.Sprintf("input %d (%#v): %v", i, in, renderErr(err)))
+		}
+	}
+	if len(mismatches) > 0 {
+		Now(append([]interface{}{fmt.Sprintf("want error %v for all inputs:\n%s", want, strings.Join(mismatches, "\n"))}, args...)...)
+	}
+}
+
+// IfErrNotAs panics if err is nil or there is no error of type T in its chain, constructing a failure message with
+// the error and args. Otherwise it returns the first error of type T in the chain, found using errors.As, for further
+// checks. It must be used in conjunction with Using.
+//
+//	pathErr := fail.IfErrNotAs[*fs.PathError](err)
+//	fail.If(pathErr.Op != "open", pathErr.Op, " != open")
+func IfErrNotAs[T error](err error, args ...interface{}) T {
+	var target T
+	if err == nil {
+		Now(append([]interface{}{fmt.Sprintf("expected an error of type %s, got nil", reflect.TypeOf(&target).Elem())}, args...)...)
+		return target
+	}
+	if !errors.As(err, &target) {
+		Now(append([]interface{}{fmt.Sprintf("no error of type %s in %v", reflect.TypeOf(&target).Elem(), renderErr(err))}, args...)...)
+	}
+	return target
+}
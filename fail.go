@@ -18,14 +18,25 @@
 // 		expected := "expected data"
 // 		fail.If(string(data) != expected, string(data), " != ", expected)
 // 	}
+//
+// Assertions for os/exec, log/slog, net/http and database/sql are provided by the subpackages failexec, failslog,
+// failhttp and failsql, so that importing package fail does not pull in those packages or require the newer Go
+// version that log/slog needs.
 package fail
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"testing"
 	"time"
+	"unicode"
 )
 
 func clean(str string) string {
@@ -40,50 +51,282 @@ func clean(str string) string {
 	}
 }
 
+// squash removes the frames of this package and of runtime panics from trace, along with the frames above the first
+// frame of this package and those from testing.tRunner onwards. It returns false if trace has no frames of this
+// package.
 func squash(trace []string) ([]string, bool) {
 	squashed := []string{""}
-	found, include, strippedPath := false, false, false
+	found, skip := false, false
 	for _, part := range trace {
-		if strings.Contains(part, pkgPath) {
-			found, strippedPath = true, true
-		} else if strings.HasPrefix(strings.TrimSpace(part), "testing.tRunner") {
-			include, found = false, false
-		} else if found {
-			include = true
-		}
-		if include {
+		if strings.HasPrefix(part, "\t") {
+			// The location of the function on the previous line.
+			if found && !skip {
+				squashed = append(squashed, clean(part))
+			}
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(part), "testing.tRunner") {
+			break
+		}
+		own := inPackage(strings.TrimPrefix(part, "created by "))
+		found = found || own
+		skip = own || strings.HasPrefix(part, "panic(")
+		if found && !skip {
 			squashed = append(squashed, clean(part))
 		}
 	}
-	if !strippedPath {
+	if !found {
 		return trace, false
 	}
 	return squashed, true
 }
 
+// inPackage returns true if fn, a function in a stack trace, belongs to this package or one of its subpackages, but
+// not to their external tests.
+func inPackage(fn string) bool {
+	return strings.HasPrefix(fn, pkgPath+".") || strings.HasPrefix(fn, pkgPath+"/") && !strings.Contains(fn, "_test.")
+}
+
+// fold collapses consecutive identical frames in trace, such as those produced by recursion, into a single frame
+// annotated with the number of repetitions.
+func fold(trace []string) []string {
+	var folded []string
+	for i := 0; i < len(trace); {
+		if i+1 >= len(trace) || strings.HasPrefix(trace[i], "\t") || !strings.HasPrefix(trace[i+1], "\t") {
+			folded = append(folded, trace[i])
+			i++
+			continue
+		}
+		n := 1
+		for i+2*n+1 < len(trace) && trace[i+2*n] == trace[i] && trace[i+2*n+1] == trace[i+1] {
+			n++
+		}
+		if n > 1 {
+			folded = append(folded, fmt.Sprintf("%s (x%d)", trace[i], n), trace[i+1])
+		} else {
+			folded = append(folded, trace[i], trace[i+1])
+		}
+		i += 2 * n
+	}
+	return folded
+}
+
 // Using recovers from panics and calls failure with the result of the recovery. It must be used as part of a deferred
 // call.
 func Using(failer func(...interface{})) {
 	r := recover()
 
+	failer = active(failer)
+	if res, ok := settle(r); ok {
+		failer(res...)
+	}
+}
+
+// Prefixed returns a failer that prepends prefix to the failure message before calling failer. It can be used to tag
+// every failure from a suite, for example defer fail.Using(fail.Prefixed("[payments] ", t.Fatal)). The prefix is
+// joined to the first argument, so no separator is added after it.
+func Prefixed(prefix string, failer func(...interface{})) func(...interface{}) {
+	return func(args ...interface{}) {
+		if len(args) == 0 {
+			failer(prefix)
+			return
+		}
+		failer(append([]interface{}{prefix + fmt.Sprint(args[0])}, args[1:]...)...)
+	}
+}
+
+var failers []func(...interface{})
+var pushed func(...interface{})
+
+// PushFailer overrides the failer used by Using with f until pop is called. The failer in effect when a failure
+// occurs is used to report it, even if pop has been called by the time the failure is recovered. This allows deferred
+// calls to pop, which run before the enclosing Using. It is intended for helpers that annotate failure messages
+// before delegating to the original failer.
+//
+//	pop := fail.PushFailer(func(args ...interface{}) {
+//		t.Fatal(append([]interface{}{"in helper:"}, args...)...)
+//	})
+//	defer pop()
+func PushFailer(f func(...interface{})) (pop func()) {
+	n := len(failers)
+	failers = append(failers, f)
+	return func() {
+		if len(failers) > n {
+			failers = failers[:n]
+		}
+	}
+}
+
+// active returns the failer pushed when the current failure occurred, or failer if there was none.
+func active(failer func(...interface{})) func(...interface{}) {
+	if pushed != nil {
+		return pushed
+	}
+	return failer
+}
+
+// capture records the failer in effect for reporting the current failure, unless the first failure since the last
+// recovery already recorded one.
+func capture() {
+	if !failing && pushed == nil && len(failers) > 0 {
+		pushed = failers[len(failers)-1]
+	}
+}
+
+// UsingFile recovers from panics like Using, but also writes the failure message and stack to a timestamped file
+// under dir before calling t.Fatal. The path of the file is included in the t.Fatal output. It must be used as part
+// of a deferred call.
+func UsingFile(t testing.TB, dir string) {
+	res, ok := settle(recover())
+	if !ok {
+		return
+	}
+	path := filepath.Join(dir, "fail-"+time.Now().Format("20060102-150405.000000000")+".log")
+	err := os.MkdirAll(dir, 0755)
+	if err == nil {
+		err = os.WriteFile(path, []byte(fmt.Sprintln(res...)), 0644)
+	}
+	if err != nil {
+		t.Fatal(append(res, "\nfailed to write failure to ", path, ": ", err)...)
+	} else {
+		t.Fatal(append(res, "\nfailure written to ", path)...)
+	}
+}
+
+// UsingStructured recovers from panics like Using, but logs a machine readable line describing the failure using
+// t.Logf before calling t.Fatal. It must be used as part of a deferred call. The line has the format
+//
+//	fail: error="<message>"
+//
+// where <message> is the failure message without the stack trace, quoted using strconv.Quote.
+func UsingStructured(t testing.TB) {
+	r := recover()
+	msg := messages(r)
+	res, ok := settle(r)
+	if !ok {
+		return
+	}
+	t.Logf("fail: error=%s", strconv.Quote(msg))
+	t.Fatal(res...)
+}
+
+var onFail []func()
+
+// OnFail registers fn to be called when a failure is recovered by Using, just before the failer is called. It is
+// intended for capturing diagnostics such as memory statistics or open files. Callbacks are called in the order they
+// were registered and are cleared when the enclosing Using returns, whether or not a failure occurred.
+func OnFail(fn func()) {
+	onFail = append(onFail, fn)
+}
+
+// settle ends the scope of a Using call, converting r into the arguments for a failer and calling the OnFail
+// callbacks if a failure was recovered.
+func settle(r interface{}) ([]interface{}, bool) {
+	callbacks := onFail
+	onFail = nil
+	res, ok := recovered(r)
+	if ok {
+		for _, fn := range callbacks {
+			fn()
+		}
+		if includeBuildInfo {
+			res = append(res, buildInfo())
+		}
+	}
+	return res, ok
+}
+
+var includeBuildInfo = false
+
+// SetIncludeBuildInfo controls whether failures recovered by Using include a footer with the Go version, GOOS,
+// GOARCH and, if available, the version control revision the test binary was built from. It is disabled by default.
+func SetIncludeBuildInfo(include bool) {
+	includeBuildInfo = include
+}
+
+func buildInfo() string {
+	footer := fmt.Sprintf("\n--- build info ---\ngo: %s\nos/arch: %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				footer += "\nrevision: " + s.Value
+			}
+		}
+	}
+	return footer
+}
+
+// recovered converts the result of a recovery into the arguments for a failer. It returns false if nothing was
+// recovered and no failures were collected, and re-panics if r is not a failure.
+func recovered(r interface{}) ([]interface{}, bool) {
 	switch f := r.(type) {
 	case nil:
-		return
+		if len(queue) == 0 {
+			return nil, false
+		}
+		return drain(nil), true
 	case failure:
-		trace := strings.Split(string(debug.Stack()), "\n")
-		squashed, more := squash(trace)
-		for i := 0; i < 3 && more; i++ {
-			squashed, more = squash(squashed)
-		}
-		res := append(f, strings.Join(squashed, "\n"))
-		res = append(res, queue...)
-		failing, queue = false, []interface{}{}
-		failer(res...)
+		return drain(append(f, stack())), true
 	default:
+		if repanicWithStack {
+			panic(stackPanic{value: r, stack: string(debug.Stack())})
+		}
 		panic(r)
 	}
 }
 
+var repanicWithStack = false
+
+// SetRepanicWithStack controls how Using handles panics with values that are not failures. By default the value is
+// passed to panic unchanged. If withStack is true, the value is wrapped together with the stack captured while
+// recovering it, so that the stack of the original panic is visible when the program crashes.
+func SetRepanicWithStack(withStack bool) {
+	repanicWithStack = withStack
+}
+
+type stackPanic struct {
+	value interface{}
+	stack string
+}
+
+func (p stackPanic) String() string {
+	return fmt.Sprintf("%v\n\nrecovered by fail.Using at:\n%s", p.value, p.stack)
+}
+
+// stack returns the current stack, with the frames of this package removed.
+func stack() string {
+	return strings.Join(SquashStack(strings.Split(string(debug.Stack()), "\n")), "\n")
+}
+
+// SquashStack removes the frames of this package, runtime panic frames and the frames above them from trace, which is
+// a stack trace in the format produced by debug.Stack, already split into lines. Function arguments and program
+// counter offsets are removed and consecutive identical frames are folded. If trace contains no frames of this
+// package, only folding is applied.
+func SquashStack(trace []string) []string {
+	squashed, more := squash(trace)
+	for more {
+		squashed, more = squash(squashed)
+	}
+	return fold(squashed)
+}
+
+// drain appends the collected failures to res and resets the failure state. Collected failures are in the order
+// they occurred. If SetVerbose is in effect, each is prefixed with the time at which it was collected.
+func drain(res []interface{}) []interface{} {
+	for _, q := range queue {
+		if observer != nil {
+			res = append(res, "\n"+q.at.Format("15:04:05.000000")+" "+q.full)
+			continue
+		}
+		res = append(res, "\n"+q.full)
+	}
+	if suppressed > 0 {
+		res = append(res, fmt.Sprintf("\n+%d more suppressed", suppressed))
+	}
+	failing, queue, suppressed, pushed = false, nil, 0, nil
+	return res
+}
+
 type failure []interface{}
 
 type namer struct{}
@@ -93,6 +336,7 @@ var pkgPath = reflect.TypeOf(namer{}).PkgPath()
 // TimedOut returns true if the function passed in takes longer than
 // timeout to run.
 func TimedOut(fn func(), timeout time.Duration) bool {
+	defer observe("TimedOut", time.Now())
 	ch := make(chan struct{})
 	go func() {
 		fn()
@@ -110,7 +354,7 @@ func TimedOut(fn func(), timeout time.Duration) bool {
 // It must be used in conjunction with Using.
 func IfErr(err error, args ...interface{}) {
 	if err != nil {
-		Now(append([]interface{}{err}, args...)...)
+		Now(append([]interface{}{renderErr(err)}, args...)...)
 	}
 }
 
@@ -126,7 +370,7 @@ func IfErr(err error, args ...interface{}) {
 // 	}
 func IfDeferred(fn func() error, args ...interface{}) {
 	if err := fn(); err != nil {
-		Now(append([]interface{}{err}, args...)...)
+		Now(append([]interface{}{renderErr(err)}, args...)...)
 	}
 }
 
@@ -139,29 +383,138 @@ func If(condition bool, args ...interface{}) {
 }
 
 var failing = false
-var queue = []interface{}{}
+var queue []queued
+var maxFailures, suppressed = 0, 0
+
+// queued is a failure collected for reporting with the next recovered failure. msg is the failure message and full
+// is the text reported, which may include stack traces.
+type queued struct {
+	msg, full string
+	at        time.Time
+}
+
+// SetMaxFailures limits the number of failures that are collected in addition to the original failure, such as
+// failures in deferred calls or in Soft, to n. It also limits the number of failing elements listed by All. Failures
+// beyond the limit are counted and reported as suppressed. A value of 0, the default, means there is no limit. It does
+// not affect the original failure.
+func SetMaxFailures(n int) {
+	maxFailures = n
+}
 
 func enqueue(f interface{}) {
 	defer func() {
-		r := recover().(failure)
-		squashed := []string{"", "Failure on defer: " + fmt.Sprintln(r...)}
-		queue = append(queue, strings.Join(squashed, "\n"))
+		msg := "Failure on defer: " + fmt.Sprintln(recover().(failure)...)
+		collect(msg, msg)
 	}()
 	panic(f)
 }
 
-// Message returns a failure message that can be recovered by a call to Using.
+// collect adds a failure with message msg to the failures reported with the next recovered failure. full is the
+// text reported for the failure.
+func collect(msg, full string) {
+	if maxFailures > 0 && len(queue) >= maxFailures {
+		suppressed++
+		return
+	}
+	queue = append(queue, queued{msg: msg, full: full, at: time.Now()})
+}
+
+// messages returns the message of r, if it is a failure, followed by the messages of the collected failures, without
+// stack traces.
+func messages(r interface{}) string {
+	var msgs []string
+	if f, ok := r.(failure); ok {
+		msgs = append(msgs, strings.TrimSpace(fmt.Sprintln(f...)))
+	}
+	for _, q := range queue {
+		msgs = append(msgs, strings.TrimSpace(q.msg))
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Message returns a failure message that can be recovered by a call to Using. If Message is called from a test, the
+// name of the test function is prepended to the message.
 func Message(args ...interface{}) interface{} {
+	capture()
 	failing = true
-	return failure(args)
+	return failure(named(renderArgs(args)))
+}
+
+// named prepends the name of the test function calling this package to args, if there is one.
+func named(args []interface{}) []interface{} {
+	if name := testName(strings.Split(string(debug.Stack()), "\n")); name != "" {
+		return append([]interface{}{name + ":"}, args...)
+	}
+	return args
+}
+
+// unnamed removes the name prepended by named from f, so that f can be passed to Now again without repeating it.
+func unnamed(f failure) failure {
+	if name := named(nil); len(name) > 0 && len(f) > 0 && f[0] == name[0] {
+		return f[1:]
+	}
+	return f
+}
+
+// testName returns the name of the test function in trace, which is the function called by testing.tRunner, without
+// its package path. It returns "" if trace does not contain a test.
+func testName(trace []string) string {
+	fn := ""
+	for _, part := range trace {
+		if strings.HasPrefix(strings.TrimSpace(part), "testing.tRunner") {
+			fn = funcName(fn[strings.LastIndex(fn, "/")+1:])
+			if closure := strings.Index(fn, ".func"); closure != -1 {
+				fn = fn[:closure]
+			}
+			return fn
+		}
+		if !strings.HasPrefix(part, "\t") {
+			fn = clean(part)
+		}
+	}
+	return ""
+}
+
+// funcName returns the name of the function in fn, which is qualified by the last element of its package path.
+// Dots in the path element are escaped in stack traces, but if they are not, the function name is taken to start
+// at the first exported identifier, as test functions do.
+func funcName(fn string) string {
+	parts := strings.Split(fn, ".")
+	for i, p := range parts[1:] {
+		if p != "" && unicode.IsUpper(rune(p[0])) {
+			return strings.Join(parts[i+1:], ".")
+		}
+	}
+	return fn[strings.Index(fn, ".")+1:]
 }
 
 // Now is equivalent to panic(Message(...)). However, if Now is being called
 // as part of a deferred statement and another failure as already occurred, the
 // failure will be added to the original failure.
+//
+// If SetDryRun(true) has been called, Now collects the failure and returns instead of panicking.
 func Now(args ...interface{}) {
+	if atomic.LoadInt32(&stressing) > 0 {
+		panic(failure(named(renderArgs(args))))
+	}
+	if dryRun {
+		msg := "Would fail: " + fmt.Sprintln(named(renderArgs(args))...)
+		capture()
+		collect(msg, msg+stack())
+		return
+	}
 	if !failing {
 		panic(Message(args...))
 	}
 	enqueue(Message(args...))
 }
+
+var dryRun = false
+
+// SetDryRun controls whether failures stop a test. If enabled is true, Now collects failures and returns instead of
+// panicking, and the enclosing Using reports every collected failure when its scope ends. This makes it possible to see
+// all the checks a new test would fail at once. Unlike Soft, it changes the behaviour of every check in the package,
+// and code following a failed check continues to run.
+func SetDryRun(enabled bool) {
+	dryRun = enabled
+}
@@ -0,0 +1,148 @@
+package fail_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sridharv/fail"
+)
+
+const recursionDepth = 10
+
+func recurse(n int) {
+	if n == 0 {
+		fail.Now("deep failure")
+	}
+	recurse(n - 1)
+}
+
+func TestFoldRecursiveStack(t *testing.T) {
+	var trace string
+	func() {
+		defer fail.Using(func(args ...interface{}) { trace = fmt.Sprint(args...) })
+		recurse(recursionDepth)
+	}()
+	want := fmt.Sprintf("(x%d)", recursionDepth)
+	if n := strings.Count(trace, want); n != 1 {
+		t.Fatalf("trace contains %q %d times, want 1:\n%s", want, n, trace)
+	}
+}
+
+// fatalRecorder is a testing.TB that records the arguments to Fatal instead of stopping the test.
+type fatalRecorder struct {
+	testing.TB
+	fatal string
+}
+
+func (r *fatalRecorder) Log(...interface{}) {}
+
+func (r *fatalRecorder) Logf(string, ...interface{}) {}
+
+func (r *fatalRecorder) Fatal(args ...interface{}) {
+	r.fatal = fmt.Sprint(args...)
+}
+
+func TestStackOmitsPackageFrames(t *testing.T) {
+	recorder := func(trace *string) func(...interface{}) {
+		return func(args ...interface{}) { *trace = fmt.Sprint(args...) }
+	}
+	cases := map[string]func() string{
+		"Using": func() (trace string) {
+			defer fail.Using(recorder(&trace))
+			fail.If(true, "failure")
+			return
+		},
+		"UsingLog": func() (trace string) {
+			defer fail.UsingLog(recorder(&trace))
+			fail.If(true, "failure")
+			return
+		},
+		"UsingTAP": func() string {
+			var buf strings.Builder
+			func() {
+				defer fail.UsingTAP(&buf, 1)
+				fail.If(true, "failure")
+			}()
+			return buf.String()
+		},
+		"UsingStructured": func() string {
+			r := &fatalRecorder{TB: t}
+			func() {
+				defer fail.UsingStructured(r)
+				fail.If(true, "failure")
+			}()
+			return r.fatal
+		},
+		"Repeat": func() (trace string) {
+			defer fail.Using(recorder(&trace))
+			fail.Repeat(2, func() { fail.If(true, "failure") })
+			return
+		},
+	}
+	for name, run := range cases {
+		trace := run()
+		for _, frame := range []string{"github.com/sridharv/fail.Now", "github.com/sridharv/fail.If", "panic"} {
+			if strings.Contains(trace, frame) {
+				t.Errorf("%s: trace contains %q:\n%s", name, frame, trace)
+			}
+		}
+		if !strings.Contains(trace, "fail_test.TestStackOmitsPackageFrames") {
+			t.Errorf("%s: trace does not contain the test:\n%s", name, trace)
+		}
+	}
+}
+
+func TestSoftFailuresNotRecoveredByLaterHelpers(t *testing.T) {
+	cases := map[string]func(){
+		"Repeat": func() {
+			fail.Repeat(3, func() {})
+		},
+		"ExpectFail": func() {
+			r := &fatalRecorder{TB: t}
+			fail.ExpectFail(r, func() {})
+			if !strings.Contains(r.fatal, "did not occur") {
+				t.Errorf("ExpectFail: got %q, want a missing failure", r.fatal)
+			}
+		},
+	}
+	for name, run := range cases {
+		var msg string
+		func() {
+			defer fail.Using(func(args ...interface{}) { msg = fmt.Sprint(args...) })
+			fail.Soft(func() { fail.If(true, "soft") })
+			run()
+		}()
+		if !strings.Contains(msg, "Soft failure: ") || strings.Contains(msg, "iteration") {
+			t.Errorf("%s: got %q, want only the soft failure", name, msg)
+		}
+	}
+}
+
+func TestCollectedFailuresUsePushedFailer(t *testing.T) {
+	var base, pushed string
+	func() {
+		defer fail.Using(func(args ...interface{}) { base = fmt.Sprint(args...) })
+		pop := fail.PushFailer(func(args ...interface{}) { pushed = fmt.Sprint(args...) })
+		defer pop()
+		fail.Soft(func() { fail.If(true, "soft") })
+	}()
+	if base != "" || !strings.Contains(pushed, "soft") {
+		t.Errorf("base failer got %q, pushed failer got %q, want the soft failure reported by the pushed failer", base, pushed)
+	}
+}
+
+func TestDryRunUsesPushedFailer(t *testing.T) {
+	defer fail.SaveConfig()()
+	fail.SetDryRun(true)
+	var base, pushed string
+	func() {
+		defer fail.Using(func(args ...interface{}) { base = fmt.Sprint(args...) })
+		pop := fail.PushFailer(func(args ...interface{}) { pushed = fmt.Sprint(args...) })
+		defer pop()
+		fail.If(true, "dry")
+	}()
+	if base != "" || !strings.Contains(pushed, "Would fail") {
+		t.Errorf("base failer got %q, pushed failer got %q, want the dry run failure reported by the pushed failer", base, pushed)
+	}
+}
@@ -0,0 +1,45 @@
+// Package failexec provides fail style assertions for commands run using os/exec.
+//
+// Commands are run to completion and their combined stdout and stderr is included in failure messages, so that a
+// command that exits unexpectedly can be diagnosed from the test output alone:
+//
+//	func TestCLI(t *testing.T) {
+//		defer fail.Using(t.Fatal)
+//		failexec.IfCmdFails(exec.Command("./mytool", "-version"))
+//		failexec.IfCmdExit(exec.Command("./mytool", "-bad-flag"), 2)
+//	}
+package failexec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/sridharv/fail"
+)
+
+// IfCmdFails runs cmd and panics if it does not exit successfully, constructing a failure message with the exit code,
+// the combined stdout and stderr of cmd and args. It must be used in conjunction with fail.Using.
+func IfCmdFails(cmd *exec.Cmd, args ...interface{}) {
+	IfCmdExit(cmd, 0, args...)
+}
+
+// IfCmdExit runs cmd and panics if it does not exit with wantCode, constructing a failure message with the exit code,
+// the combined stdout and stderr of cmd and args. It must be used in conjunction with fail.Using.
+func IfCmdExit(cmd *exec.Cmd, wantCode int, args ...interface{}) {
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	code, err := 0, cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		code = exitErr.ExitCode()
+	case err != nil:
+		fail.Now(append([]interface{}{fmt.Sprintf("%v: %v", cmd, err)}, args...)...)
+		return
+	}
+	if code != wantCode {
+		fail.Now(append([]interface{}{fmt.Sprintf("%v exited with code %d, want %d, output:\n%s", cmd, code, wantCode, out.String())}, args...)...)
+	}
+}
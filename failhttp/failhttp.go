@@ -0,0 +1,54 @@
+// Package failhttp provides fail style assertions for net/http.
+//
+// The assertions check an *http.Response, so they can be used with responses from a real server as well as those
+// recorded by net/http/httptest in handler tests:
+//
+//	func TestHandler(t *testing.T) {
+//		defer fail.Using(t.Fatal)
+//		rec := httptest.NewRecorder()
+//		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/users/1", nil))
+//		resp := rec.Result()
+//		failhttp.IfHeader(resp, "Cache-Control", "no-store")
+//		failhttp.IfHeaderContains(resp, "Content-Type", "application/json")
+//	}
+package failhttp
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sridharv/fail"
+)
+
+// IfHeader panics if the value of the header key in resp, as returned by resp.Header.Get, is not want, constructing
+// a failure message with the actual value, or absent if the header is not set, and args. It must be used in
+// conjunction with fail.Using.
+func IfHeader(resp *http.Response, key, want string, args ...interface{}) {
+	got, ok := header(resp, key)
+	switch {
+	case !ok:
+		fail.Now(append([]interface{}{fmt.Sprintf("header %s absent, want %q", key, want)}, args...)...)
+	case got != want:
+		fail.Now(append([]interface{}{fmt.Sprintf("header %s: %q != %q", key, got, want)}, args...)...)
+	}
+}
+
+// IfHeaderContains is like IfHeader, but only panics if the value of the header does not contain substr. It is
+// useful for headers with parameters, such as a Content-Type of "application/json; charset=utf-8".
+func IfHeaderContains(resp *http.Response, key, substr string, args ...interface{}) {
+	got, ok := header(resp, key)
+	switch {
+	case !ok:
+		fail.Now(append([]interface{}{fmt.Sprintf("header %s absent, want it to contain %q", key, substr)}, args...)...)
+	case !strings.Contains(got, substr):
+		fail.Now(append([]interface{}{fmt.Sprintf("header %s: %q does not contain %q", key, got, substr)}, args...)...)
+	}
+}
+
+func header(resp *http.Response, key string) (string, bool) {
+	if _, ok := resp.Header[http.CanonicalHeaderKey(key)]; !ok {
+		return "", false
+	}
+	return resp.Header.Get(key), true
+}
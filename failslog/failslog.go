@@ -0,0 +1,74 @@
+//go:build go1.21
+
+// Package failslog provides fail style assertions for log/slog. It requires Go 1.21 or later.
+//
+// CaptureSlog returns a handler to pass to the code under test in place of its usual handler. The records it
+// captures are checked by level and message:
+//
+//	func TestLogging(t *testing.T) {
+//		defer fail.Using(t.Fatal)
+//		handler, assert := failslog.CaptureSlog()
+//		DoSomething(slog.New(handler))
+//		assert(slog.LevelWarn, "retrying")
+//	}
+package failslog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/sridharv/fail"
+)
+
+type records struct {
+	mu      sync.Mutex
+	entries []slog.Record
+}
+
+// captureHandler records log records. Only the level and message of records are asserted on, so attributes and
+// groups are ignored.
+type captureHandler struct {
+	records *records
+}
+
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *captureHandler) Handle(_ context.Context, r slog.Record) error {
+	h.records.mu.Lock()
+	defer h.records.mu.Unlock()
+	h.records.entries = append(h.records.entries, r.Clone())
+	return nil
+}
+
+func (h *captureHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+func (h *captureHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// CaptureSlog returns a slog.Handler that records every log record it handles, and an assert function that panics if
+// no recorded record has the given level and a message containing msgSubstr, constructing a failure message listing
+// the recorded records. The handler is safe for concurrent use. assert must be used in conjunction with fail.Using.
+func CaptureSlog() (handler slog.Handler, assert func(level slog.Level, msgSubstr string)) {
+	recs := &records{}
+	assert = func(level slog.Level, msgSubstr string) {
+		recs.mu.Lock()
+		defer recs.mu.Unlock()
+		var seen []string
+		for _, r := range recs.entries {
+			if r.Level == level && strings.Contains(r.Message, msgSubstr) {
+				return
+			}
+			seen = append(seen, fmt.Sprintf("%v %q", r.Level, r.Message))
+		}
+		fail.Now(fmt.Sprintf("no %v record containing %q, recorded:\n%s", level, msgSubstr, strings.Join(seen, "\n")))
+	}
+	return &captureHandler{records: recs}, assert
+}
@@ -0,0 +1,49 @@
+// Package failsql provides fail style assertions for database/sql.
+//
+// Failure messages distinguish a query that returned no rows from a driver error, and report the number of rows a
+// statement actually changed:
+//
+//	func TestUpdate(t *testing.T) {
+//		defer fail.Using(t.Fatal)
+//		res, err := db.Exec("UPDATE users SET name = ? WHERE id = ?", "alice", 1)
+//		fail.IfErr(err)
+//		failsql.IfRowsAffected(res, 1)
+//
+//		var name string
+//		failsql.IfNoRows(db.QueryRow("SELECT name FROM users WHERE id = ?", 1).Scan(&name))
+//	}
+package failsql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/sridharv/fail"
+)
+
+// IfRowsAffected panics if r did not affect exactly want rows, constructing a failure message with the actual and
+// expected counts and args. It also panics with the driver error if the number of rows affected is not available. It
+// must be used in conjunction with fail.Using.
+func IfRowsAffected(r sql.Result, want int64, args ...interface{}) {
+	n, err := r.RowsAffected()
+	if err != nil {
+		fail.Now(append([]interface{}{"rows affected not available:", err}, args...)...)
+		return
+	}
+	if n != want {
+		fail.Now(append([]interface{}{fmt.Sprintf("%d rows affected, want %d", n, want)}, args...)...)
+	}
+}
+
+// IfNoRows panics if err is sql.ErrNoRows, constructing a failure message stating that no rows were returned and
+// args. It also panics with err if err is any other non-nil error, such as an error from the driver. It must be used
+// in conjunction with fail.Using.
+func IfNoRows(err error, args ...interface{}) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		fail.Now(append([]interface{}{"no rows returned:", err}, args...)...)
+	case err != nil:
+		fail.IfErr(err, args...)
+	}
+}
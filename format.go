@@ -0,0 +1,60 @@
+package fail
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// IfNotFormat panics if validate returns an error for s, constructing a failure message with s, the validation error
+// and args. It must be used in conjunction with Using.
+func IfNotFormat(s string, validate func(string) error, args ...interface{}) {
+	if err := validate(s); err != nil {
+		Now(append([]interface{}{fmt.Sprintf("%q is not valid: %v", s, err)}, args...)...)
+	}
+}
+
+// IfNotUUID panics if s is not a UUID in the standard hyphenated form, such as
+// "123e4567-e89b-12d3-a456-426614174000", constructing a failure message with s, the problem and args. Hexadecimal
+// digits may be upper or lower case. It must be used in conjunction with Using.
+func IfNotUUID(s string, args ...interface{}) {
+	IfNotFormat(s, validateUUID, args...)
+}
+
+func validateUUID(s string) error {
+	if len(s) != 36 {
+		return fmt.Errorf("length %d, want 36", len(s))
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return fmt.Errorf("want '-' at offset %d, got %q", i, c)
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return fmt.Errorf("invalid hex digit %q at offset %d", c, i)
+			}
+		}
+	}
+	return nil
+}
+
+// IfNotUTF8 panics if s is not valid UTF-8, constructing a failure message with s, the byte offset of the first invalid
+// sequence and args. It must be used in conjunction with Using.
+func IfNotUTF8(s string, args ...interface{}) {
+	IfNotFormat(s, validateUTF8, args...)
+}
+
+func validateUTF8(s string) error {
+	if utf8.ValidString(s) {
+		return nil
+	}
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			return fmt.Errorf("invalid UTF-8 byte 0x%02x at offset %d", s[i], i)
+		}
+		i += size
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package fail
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// IfTreeNotEqual walks the directory tree at root and panics if the entries in it are not exactly want, constructing
+// a failure message listing the missing and extra entries and args. Entries are slash separated paths relative to
+// root, with directories ending in a slash, for example []string{"cmd/", "cmd/main.go", "go.mod"}. The order of want
+// does not matter. It also panics if walking root fails. It must be used in conjunction with Using.
+func IfTreeNotEqual(root string, want []string, args ...interface{}) {
+	var got []string
+	walkTree(root, args, func(rel string, d fs.DirEntry) {
+		if d.IsDir() {
+			rel += "/"
+		}
+		got = append(got, rel)
+	})
+	ifEntriesDiffer(got, want, args)
+}
+
+// IfTreeContentsNotEqual is like IfTreeNotEqual, but only considers regular files and also compares their contents.
+// want maps the slash separated path of each file relative to root to its expected contents.
+func IfTreeContentsNotEqual(root string, want map[string]string, args ...interface{}) {
+	got := map[string]string{}
+	walkTree(root, args, func(rel string, d fs.DirEntry) {
+		if !d.Type().IsRegular() {
+			return
+		}
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			Now(append([]interface{}{fmt.Sprintf("reading %s failed: %v", rel, err)}, args...)...)
+			return
+		}
+		got[rel] = string(data)
+	})
+	var gotNames, wantNames, changed []string
+	for name, contents := range got {
+		gotNames = append(gotNames, name)
+		if w, ok := want[name]; ok && w != contents {
+			changed = append(changed, fmt.Sprintf("%s: %q != %q", name, contents, w))
+		}
+	}
+	for name := range want {
+		wantNames = append(wantNames, name)
+	}
+	ifEntriesDiffer(gotNames, wantNames, args)
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		Now(append([]interface{}{"file contents differ:\n" + strings.Join(changed, "\n")}, args...)...)
+	}
+}
+
+func walkTree(root string, args []interface{}, visit func(rel string, d fs.DirEntry)) {
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		visit(filepath.ToSlash(rel), d)
+		return nil
+	})
+	if err != nil {
+		Now(append([]interface{}{fmt.Sprintf("walking %s failed: %v", root, err)}, args...)...)
+	}
+}
+
+func ifEntriesDiffer(got, want []string, args []interface{}) {
+	present := map[string]int{}
+	for _, g := range got {
+		present[g]++
+	}
+	for _, w := range want {
+		present[w]--
+	}
+	var missing, extra []string
+	for name, n := range present {
+		switch {
+		case n > 0:
+			extra = append(extra, name)
+		case n < 0:
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 && len(extra) == 0 {
+		return
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	Now(append([]interface{}{fmt.Sprintf("directory tree differs\nmissing: %v\nextra: %v", missing, extra)}, args...)...)
+}
@@ -0,0 +1,51 @@
+package fail
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// IfNotCollected obtains an object from setup, which must return a pointer, and panics if the object is not garbage
+// collected within settle, constructing a failure message indicating a probable leak and args. Garbage collection is
+// forced repeatedly while waiting. setup must not keep a reference to the object. It must be used in conjunction with
+// Using.
+//
+// IfNotCollected is best-effort: the runtime does not guarantee when, or whether, a finalizer runs, so a passing
+// check proves nothing and a generous settle is needed to keep failures meaningful. It cannot be used with objects
+// that already have a finalizer.
+func IfNotCollected(setup func() interface{}, settle time.Duration, args ...interface{}) {
+	var collected int32
+	if msg := watch(setup, &collected); msg != "" {
+		Now(append([]interface{}{msg}, args...)...)
+		return
+	}
+	defer observe("IfNotCollected", time.Now())
+	deadline := time.Now().Add(settle)
+	for {
+		runtime.GC()
+		if atomic.LoadInt32(&collected) != 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			Now(append([]interface{}{fmt.Sprintf("object not collected after %v, probable leak", settle)}, args...)...)
+			return
+		}
+		time.Sleep(settle / 10)
+	}
+}
+
+// watch sets a finalizer on the object returned by setup that sets collected. It is a separate function so that no
+// reference to the object remains on the stack of IfNotCollected.
+//
+//go:noinline
+func watch(setup func() interface{}, collected *int32) string {
+	obj := setup()
+	if v := reflect.ValueOf(obj); v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Sprintf("IfNotCollected: setup returned %T, want a non-nil pointer", obj)
+	}
+	runtime.SetFinalizer(obj, func(interface{}) { atomic.StoreInt32(collected, 1) })
+	return ""
+}
@@ -0,0 +1,3 @@
+module github.com/sridharv/fail
+
+go 1.20
@@ -0,0 +1,76 @@
+package fail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// IfReadLen reads up to want+1 bytes from r and panics if it does not yield exactly want bytes, constructing a
+// failure message describing whether the read was short or more data was available, and args. It also panics if
+// reading fails with an error other than io.EOF. It must be used in conjunction with Using.
+func IfReadLen(r io.Reader, want int, args ...interface{}) {
+	buf := make([]byte, want+1)
+	n, err := io.ReadFull(r, buf)
+	switch {
+	case err != nil && err != io.EOF && err != io.ErrUnexpectedEOF:
+		Now(append([]interface{}{fmt.Sprintf("read failed after %d bytes: %v", n, err)}, args...)...)
+	case n < want:
+		Now(append([]interface{}{fmt.Sprintf("short read: got %d bytes, want %d", n, want)}, args...)...)
+	case n > want:
+		Now(append([]interface{}{fmt.Sprintf("extra data: more than %d bytes available", want)}, args...)...)
+	}
+}
+
+const streamChunk = 32 * 1024
+
+// IfStreamsNotEqual reads a and b in chunks and panics at the first offset at which they differ, constructing a
+// failure message with a hex dump of the differing bytes and args. A stream that is a prefix of the other is reported
+// as differing at the end of the shorter stream. It also panics if reading either stream fails. It must be used in
+// conjunction with Using.
+func IfStreamsNotEqual(a, b io.Reader, args ...interface{}) {
+	bufA, bufB := make([]byte, streamChunk), make([]byte, streamChunk)
+	for offset := 0; ; offset += streamChunk {
+		na, errA := readChunk(a, bufA)
+		nb, errB := readChunk(b, bufB)
+		for _, err := range []error{errA, errB} {
+			if err != nil {
+				Now(append([]interface{}{fmt.Sprintf("read failed near offset %d: %v", offset, err)}, args...)...)
+				return
+			}
+		}
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			Now(append([]interface{}{bytesDiff(bufA[:na], bufB[:nb], offset)}, args...)...)
+			return
+		}
+		if na < streamChunk {
+			return
+		}
+	}
+}
+
+// readChunk fills buf from r, returning a nil error if r ends before buf is full.
+func readChunk(r io.Reader, buf []byte) (int, error) {
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return n, err
+}
+
+// IfCloseThenUse closes closer, runs use and panics if use does not return an error matching wantErr using
+// errors.Is, constructing a failure message with the actual error and args. It also panics if closing fails. It must be
+// used in conjunction with Using.
+func IfCloseThenUse(closer io.Closer, use func() error, wantErr error, args ...interface{}) {
+	if err := closer.Close(); err != nil {
+		Now(append([]interface{}{"close failed:", renderErr(err)}, args...)...)
+		return
+	}
+	switch err := use(); {
+	case err == nil:
+		Now(append([]interface{}{fmt.Sprintf("use after close succeeded, want %v", wantErr)}, args...)...)
+	case !errors.Is(err, wantErr):
+		Now(append([]interface{}{fmt.Sprintf("use after close returned %v, want %v", renderErr(err), wantErr)}, args...)...)
+	}
+}
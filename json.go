@@ -0,0 +1,186 @@
+package fail
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IfJSONNotEqual panics if got and want are not semantically equal JSON documents, constructing a failure message
+// with the path of the first difference, such as $.items[2].name, and args. Key order and whitespace are ignored. It
+// also panics if either document is not valid JSON. It must be used in conjunction with Using.
+//
+// Numbers are compared as float64 values, following the JSON data model, so 1 and 1.0 are equal.
+func IfJSONNotEqual(got, want []byte, args ...interface{}) {
+	g, w := unmarshalJSON(got, "got", args), unmarshalJSON(want, "want", args)
+	if diff := jsonDiff("$", g, w); diff != "" {
+		Now(append([]interface{}{diff}, args...)...)
+	}
+}
+
+// IfJSONNotEqualExcept is like IfJSONNotEqual, but removes the values at ignorePaths from both documents before
+// comparing them, for fields such as timestamps and IDs that change between runs. Paths use the notation of the
+// failure messages, such as $.items[2].id, and the leading $. may be omitted. An index of [*] matches every element
+// of an array. Paths that are not present in a document are ignored. It also panics if a path is malformed.
+//
+//	fail.IfJSONNotEqualExcept(body, `{"name": "alice", "created_at": ""}`, []string{"created_at", "items[*].id"})
+func IfJSONNotEqualExcept(got []byte, wantJSON string, ignorePaths []string, args ...interface{}) {
+	g, w := unmarshalJSON(got, "got", args), unmarshalJSON([]byte(wantJSON), "want", args)
+	for _, p := range ignorePaths {
+		segments, err := parseJSONPath(p)
+		if err != nil {
+			Now(append([]interface{}{fmt.Sprintf("invalid ignore path %q: %v", p, err)}, args...)...)
+			return
+		}
+		g, w = stripJSON(g, segments), stripJSON(w, segments)
+	}
+	if diff := jsonDiff("$", g, w); diff != "" {
+		Now(append([]interface{}{diff}, args...)...)
+	}
+}
+
+// parseJSONPath splits a path such as $.items[2].id into its segments. Object keys are returned as is and array
+// indices are returned in brackets, such as [2] or [*].
+func parseJSONPath(path string) ([]string, error) {
+	rest := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	var segments []string
+	for rest != "" {
+		if rest[0] == '[' {
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated [")
+			}
+			if idx := rest[1:end]; idx != "*" {
+				if _, err := strconv.Atoi(idx); err != nil {
+					return nil, fmt.Errorf("invalid index %q", idx)
+				}
+			}
+			segments, rest = append(segments, rest[:end+1]), strings.TrimPrefix(rest[end+1:], ".")
+			continue
+		}
+		end := strings.IndexAny(rest, ".[")
+		if end == -1 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("empty key")
+		}
+		segments, rest = append(segments, rest[:end]), strings.TrimPrefix(rest[end:], ".")
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("empty path")
+	}
+	return segments, nil
+}
+
+// stripJSON returns v, which was unmarshalled from JSON, with the values at the path made up of segments removed.
+func stripJSON(v interface{}, segments []string) interface{} {
+	seg, last := segments[0], len(segments) == 1
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if _, ok := t[seg]; !ok {
+			return v
+		}
+		if last {
+			delete(t, seg)
+		} else {
+			t[seg] = stripJSON(t[seg], segments[1:])
+		}
+	case []interface{}:
+		if !strings.HasPrefix(seg, "[") {
+			return v
+		}
+		if seg == "[*]" {
+			if last {
+				return []interface{}{}
+			}
+			for i := range t {
+				t[i] = stripJSON(t[i], segments[1:])
+			}
+			return t
+		}
+		i, _ := strconv.Atoi(seg[1 : len(seg)-1])
+		if i < 0 || i >= len(t) {
+			return v
+		}
+		if last {
+			return append(t[:i:i], t[i+1:]...)
+		}
+		t[i] = stripJSON(t[i], segments[1:])
+	}
+	return v
+}
+
+func unmarshalJSON(data []byte, name string, args []interface{}) interface{} {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		Now(append([]interface{}{fmt.Sprintf("%s is not valid JSON: %v", name, err)}, args...)...)
+	}
+	return v
+}
+
+// jsonDiff describes the first difference between got and want, which were unmarshalled from JSON, or returns ""
+// if they are equal.
+func jsonDiff(path string, got, want interface{}) string {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(g)+len(w))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		for k := range g {
+			if _, ok := w[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			gv, gok := g[k]
+			wv, wok := w[k]
+			switch p := path + "." + k; {
+			case !gok:
+				return fmt.Sprintf("%s: missing, want %s", p, toJSON(wv))
+			case !wok:
+				return fmt.Sprintf("%s: unexpected %s", p, toJSON(gv))
+			default:
+				if diff := jsonDiff(p, gv, wv); diff != "" {
+					return diff
+				}
+			}
+		}
+		return ""
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(g) && i < len(w); i++ {
+			if diff := jsonDiff(fmt.Sprintf("%s[%d]", path, i), g[i], w[i]); diff != "" {
+				return diff
+			}
+		}
+		if len(g) != len(w) {
+			return fmt.Sprintf("%s: length %d != %d", path, len(g), len(w))
+		}
+		return ""
+	}
+	if reflect.DeepEqual(got, want) {
+		return ""
+	}
+	return fmt.Sprintf("%s: %s != %s", path, toJSON(got), toJSON(want))
+}
+
+func toJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,85 @@
+package fail
+
+import (
+	"log"
+	"strings"
+	"sync"
+)
+
+type logRing struct {
+	mu      sync.Mutex
+	entries []string
+	next    int
+	full    bool
+}
+
+func (r *logRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) == 0 {
+		return len(p), nil
+	}
+	r.entries[r.next] = string(p)
+	r.next = (r.next + 1) % len(r.entries)
+	r.full = r.full || r.next == 0
+	return len(p), nil
+}
+
+func (r *logRing) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := r.entries[:r.next]
+	if r.full {
+		entries = append(append([]string{}, r.entries[r.next:]...), entries...)
+	}
+	return strings.Join(entries, "")
+}
+
+var logBufferSize = 20
+var captured *logRing
+
+// SetLogBufferSize sets the number of log entries retained by CaptureLog. It applies to subsequent calls to
+// CaptureLog. A size of 0 disables capture, so that no log entries are included in failures, and negative sizes are
+// treated as 0. The default is 20.
+func SetLogBufferSize(n int) {
+	if n < 0 {
+		n = 0
+	}
+	logBufferSize = n
+}
+
+// CaptureLog redirects the output of the standard log package to a buffer holding the most recent log entries.
+// Failures recovered by UsingLog include the contents of the buffer. The returned function restores the original
+// log output and must be called when capturing is done.
+//
+//	func TestSomething(t *testing.T) {
+//		defer fail.CaptureLog()()
+//		defer fail.UsingLog(t.Fatal)
+//		...
+//	}
+func CaptureLog() (restore func()) {
+	prev, prevCaptured := log.Writer(), captured
+	captured = &logRing{entries: make([]string, logBufferSize)}
+	log.SetOutput(captured)
+	return func() {
+		log.SetOutput(prev)
+		captured = prevCaptured
+	}
+}
+
+// UsingLog is like Using, but appends the log entries captured by CaptureLog to the failure, if there are any. It must
+// be used as part of a deferred call.
+func UsingLog(failer func(...interface{})) {
+	r := recover()
+	failer = active(failer)
+	res, ok := settle(r)
+	if !ok {
+		return
+	}
+	if captured != nil {
+		if out := captured.String(); out != "" {
+			res = append(res, "\nrecent log output:\n"+out)
+		}
+	}
+	failer(res...)
+}
@@ -0,0 +1,70 @@
+package fail
+
+import (
+	"fmt"
+	"math"
+)
+
+// DeltaEpsilon is the tolerance used by IfNotDelta when comparing the change in a metric with the expected change.
+const DeltaEpsilon = 1e-9
+
+// IfNotDelta reads a metric using read before and after running fn and panics if it did not change by delta, within
+// DeltaEpsilon, constructing a failure message with the values before and after, the expected change and args. It
+// must be used in conjunction with Using.
+func IfNotDelta(read func() float64, delta float64, fn func(), args ...interface{}) {
+	before := read()
+	fn()
+	after := read()
+	if math.Abs(after-before-delta) > DeltaEpsilon {
+		Now(append([]interface{}{fmt.Sprintf("metric changed from %v to %v (by %v), want change of %v", before, after, after-before, delta)}, args...)...)
+	}
+}
+
+// IfVecNotClose panics if got and want have different lengths or any pair of elements differs by more than tol,
+// constructing a failure message with the first offending index, both values and args. NaN elements are never
+// close. It must be used in conjunction with Using.
+func IfVecNotClose(got, want []float64, tol float64, args ...interface{}) {
+	ifVecNotClose(got, want, args, fmt.Sprintf("absolute tolerance %v", tol), func(g, w float64) bool {
+		return math.Abs(g-w) <= tol
+	})
+}
+
+// IfVecNotCloseRel is like IfVecNotClose, but allows each pair of elements to differ by rel times the larger of
+// their magnitudes, which suits data spanning a wide range of magnitudes.
+func IfVecNotCloseRel(got, want []float64, rel float64, args ...interface{}) {
+	ifVecNotClose(got, want, args, fmt.Sprintf("relative tolerance %v", rel), func(g, w float64) bool {
+		return math.Abs(g-w) <= rel*math.Max(math.Abs(g), math.Abs(w))
+	})
+}
+
+func ifVecNotClose(got, want []float64, args []interface{}, tolerance string, close func(g, w float64) bool) {
+	if len(got) != len(want) {
+		Now(append([]interface{}{fmt.Sprintf("length %d != %d", len(got), len(want))}, args...)...)
+		return
+	}
+	for i := range got {
+		if !close(got[i], want[i]) {
+			Now(append([]interface{}{fmt.Sprintf("index %d: %v != %v within %s", i, got[i], want[i], tolerance)}, args...)...)
+			return
+		}
+	}
+}
+
+// IfNotMonotonic panics if s is not increasing, constructing a failure message with the indices and values of the
+// first pair of elements that is out of order, and args. If strict is true, equal adjacent elements are also out of
+// order. It must be used in conjunction with Using.
+func IfNotMonotonic[T ordered](s []T, strict bool, args ...interface{}) {
+	for i := 1; i < len(s); i++ {
+		if s[i] < s[i-1] || strict && s[i] == s[i-1] {
+			Now(append([]interface{}{fmt.Sprintf("not increasing at indices %d and %d: %v, %v", i-1, i, s[i-1], s[i])}, args...)...)
+			return
+		}
+	}
+}
+
+// ordered is the set of types that support the < operator, as in the cmp package. It is declared here so that this
+// package does not require the Go version that introduced cmp.
+type ordered interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64 | ~string
+}
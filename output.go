@@ -0,0 +1,58 @@
+package fail
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// CaptureOutput runs fn with os.Stdout and os.Stderr redirected, returning what was written to each. The original
+// files are restored when fn returns, even if it panics, in which case the panic is propagated. Writes from other
+// goroutines while fn runs are also captured. It panics with a failure if the redirection cannot be set up, so it
+// must be used in conjunction with Using.
+func CaptureOutput(fn func()) (stdout, stderr string) {
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		Now("capturing stdout failed:", err)
+		return "", ""
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		outR.Close()
+		outW.Close()
+		Now("capturing stderr failed:", err)
+		return "", ""
+	}
+	var outBuf, errBuf bytes.Buffer
+	done := make(chan struct{}, 2)
+	drain := func(buf *bytes.Buffer, r *os.File) {
+		io.Copy(buf, r)
+		r.Close()
+		done <- struct{}{}
+	}
+	go drain(&outBuf, outR)
+	go drain(&errBuf, errR)
+
+	origOut, origErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outW, errW
+	defer func() {
+		os.Stdout, os.Stderr = origOut, origErr
+		outW.Close()
+		errW.Close()
+		<-done
+		<-done
+		stdout, stderr = outBuf.String(), errBuf.String()
+	}()
+	fn()
+	return
+}
+
+// IfStdoutNotContains runs fn using CaptureOutput and panics if its output to os.Stdout does not contain substr,
+// constructing a failure message with the output, substr and args. It must be used in conjunction with Using.
+func IfStdoutNotContains(fn func(), substr string, args ...interface{}) {
+	if stdout, _ := CaptureOutput(fn); !strings.Contains(stdout, substr) {
+		Now(append([]interface{}{fmt.Sprintf("stdout %q does not contain %q", stdout, substr)}, args...)...)
+	}
+}
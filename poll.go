@@ -0,0 +1,53 @@
+package fail
+
+import (
+	"fmt"
+	"time"
+)
+
+// Eventually polls cond every interval and panics if it has not returned true within timeout, constructing a failure
+// message with the number of attempts, the time elapsed and args. It must be used in conjunction with Using.
+func Eventually(cond func() bool, timeout, interval time.Duration, args ...interface{}) {
+	EventuallyN(cond, timeout, interval, 0, args...)
+}
+
+// EventuallyN is like Eventually, but also panics if cond has not returned true after maxPolls attempts. The failure
+// message states which limit was reached. A maxPolls of 0 means there is no limit on the number of attempts.
+func EventuallyN(cond func() bool, timeout, interval time.Duration, maxPolls int, args ...interface{}) {
+	start := time.Now()
+	defer observe("Eventually", start)
+	for attempts := 1; ; attempts++ {
+		if cond() {
+			return
+		}
+		elapsed := time.Since(start)
+		switch {
+		case maxPolls > 0 && attempts >= maxPolls:
+			Now(append([]interface{}{fmt.Sprintf("condition not met after %d attempts (the maximum) in %v", attempts, elapsed)}, args...)...)
+			return
+		case elapsed+interval > timeout:
+			Now(append([]interface{}{fmt.Sprintf("condition not met after %d attempts in %v (timeout %v)", attempts, elapsed, timeout)}, args...)...)
+			return
+		}
+		time.Sleep(interval)
+	}
+}
+
+// EventuallyNoErr calls fn every interval until it returns nil, and panics if it has not done so within timeout,
+// constructing a failure message with the last error, the number of attempts and args. It returns as soon as fn
+// succeeds. It must be used in conjunction with Using.
+func EventuallyNoErr(fn func() error, timeout, interval time.Duration, args ...interface{}) {
+	start := time.Now()
+	defer observe("EventuallyNoErr", start)
+	for attempts := 1; ; attempts++ {
+		err := fn()
+		if err == nil {
+			return
+		}
+		if elapsed := time.Since(start); elapsed+interval > timeout {
+			Now(append([]interface{}{fmt.Sprintf("still failing after %d attempts in %v (timeout %v): %v", attempts, elapsed, timeout, renderErr(err))}, args...)...)
+			return
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,88 @@
+package fail
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// IfNotStable calls fn the given number of times and panics if any result is not reflect.DeepEqual to the first,
+// constructing a failure message with the index of the differing run, both results and args. It must be used in
+// conjunction with Using.
+func IfNotStable[T any](fn func() T, runs int, args ...interface{}) {
+	if runs < 1 {
+		return
+	}
+	first := fn()
+	for i := 1; i < runs; i++ {
+		if v := fn(); !reflect.DeepEqual(v, first) {
+			Now(append([]interface{}{fmt.Sprintf("run %d returned %#v, run 0 returned %#v", i, v, first)}, args...)...)
+			return
+		}
+	}
+}
+
+// IfMutates runs fn with input and panics if fn modifies input, constructing a failure message describing the first
+// change and args. fn is passed input itself, and the change is detected by comparing input with a deep copy taken
+// before fn runs. It must be used in conjunction with Using.
+//
+// Unexported struct fields cannot be deep copied using reflection and are copied shallowly, so changes made through
+// pointers, slices or maps held in unexported fields are not detected.
+func IfMutates[T any](input T, fn func(T), args ...interface{}) {
+	before := deepCopy(reflect.ValueOf(&input).Elem(), map[copied]reflect.Value{}).Interface()
+	fn(input)
+	if d := diff(input, before); d != "" {
+		Now(append([]interface{}{"input was modified: " + d}, args...)...)
+	}
+}
+
+// IfNotRoundTrip encodes v using enc, decodes the result using dec and panics if either step fails or the decoded
+// value is not reflect.DeepEqual to v, constructing a failure message describing the failing step or the first
+// difference, and args. It must be used in conjunction with Using.
+func IfNotRoundTrip[T any](v T, enc func(T) ([]byte, error), dec func([]byte) (T, error), args ...interface{}) {
+	data, err := enc(v)
+	if err != nil {
+		Now(append([]interface{}{"encode failed:", renderErr(err)}, args...)...)
+		return
+	}
+	decoded, err := dec(data)
+	if err != nil {
+		Now(append([]interface{}{"decode failed:", renderErr(err)}, args...)...)
+		return
+	}
+	if d := diff(decoded, v); d != "" {
+		Now(append([]interface{}{"round trip changed value: " + d}, args...)...)
+	}
+}
+
+// IfNotIdempotent calls fn twice and panics if either call returns an error or the results are not
+// reflect.DeepEqual, constructing a failure message identifying the failing call or the first difference between the
+// results, and args. It must be used in conjunction with Using.
+func IfNotIdempotent[T any](fn func() (T, error), args ...interface{}) {
+	first, err := fn()
+	if err != nil {
+		Now(append([]interface{}{"first call failed:", renderErr(err)}, args...)...)
+		return
+	}
+	second, err := fn()
+	if err != nil {
+		Now(append([]interface{}{"second call failed:", renderErr(err)}, args...)...)
+		return
+	}
+	if d := diff(second, first); d != "" {
+		Now(append([]interface{}{"second call result differs from first: " + d}, args...)...)
+	}
+}
+
+// IfBreaksInvariant checks inv, runs fn and checks inv again, panicking if inv returns an error either time,
+// constructing a failure message stating whether the invariant failed before or after fn, the error and args. It
+// must be used in conjunction with Using.
+func IfBreaksInvariant(inv func() error, fn func(), args ...interface{}) {
+	if err := inv(); err != nil {
+		Now(append([]interface{}{"invariant violated before operation:", renderErr(err)}, args...)...)
+		return
+	}
+	fn()
+	if err := inv(); err != nil {
+		Now(append([]interface{}{"invariant violated after operation:", renderErr(err)}, args...)...)
+	}
+}
@@ -0,0 +1,90 @@
+package fail
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var maxRenderDepth = 3
+
+// compactWidth is the length up to which composite values are rendered on a single line.
+const compactWidth = 60
+
+// SetMaxRenderDepth sets the depth to which composite failure arguments, such as slices, maps and structs, are
+// expanded over multiple indented lines. Values nested more deeply, values that fit on a short line and slices of
+// scalars are rendered as they would be by fmt. A depth of 0 disables expansion. The default is 3.
+func SetMaxRenderDepth(depth int) {
+	maxRenderDepth = depth
+}
+
+// renderArgs expands the composite values in args for readability. Other values are left unchanged.
+func renderArgs(args []interface{}) []interface{} {
+	if maxRenderDepth <= 0 {
+		return args
+	}
+	rendered := make([]interface{}, len(args))
+	for i, arg := range args {
+		rendered[i] = arg
+		if v := reflect.ValueOf(arg); expandable(v) {
+			rendered[i] = pretty(v, 0, "")
+		}
+	}
+	return rendered
+}
+
+// expandable returns true if v is a composite value that does not render itself using String or Error.
+func expandable(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	if v.CanInterface() {
+		switch v.Interface().(type) {
+		case error, fmt.Stringer, []byte:
+			return false
+		}
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		return true
+	case reflect.Ptr, reflect.Interface:
+		return !v.IsNil() && v.Elem().Kind() == reflect.Struct && expandable(v.Elem())
+	}
+	return false
+}
+
+func pretty(v reflect.Value, depth int, indent string) string {
+	compact := fmt.Sprintf("%v", v)
+	if depth >= maxRenderDepth || !expandable(v) || len(compact) <= compactWidth {
+		return compact
+	}
+	inner := indent + "  "
+	var lines []string
+	switch v.Kind() {
+	case reflect.Ptr:
+		return "&" + pretty(v.Elem(), depth, indent)
+	case reflect.Interface:
+		return pretty(v.Elem(), depth, indent)
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 || !expandable(v.Index(0)) {
+			return compact
+		}
+		for i := 0; i < v.Len(); i++ {
+			lines = append(lines, inner+pretty(v.Index(i), depth+1, inner))
+		}
+		return "[\n" + strings.Join(lines, "\n") + "\n" + indent + "]"
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			lines = append(lines, fmt.Sprintf("%s%v: %s", inner, k, pretty(v.MapIndex(k), depth+1, inner)))
+		}
+		return "map[\n" + strings.Join(lines, "\n") + "\n" + indent + "]"
+	default:
+		for i := 0; i < v.NumField(); i++ {
+			lines = append(lines, fmt.Sprintf("%s%s: %s", inner, v.Type().Field(i).Name, pretty(v.Field(i), depth+1, inner)))
+		}
+		return v.Type().String() + "{\n" + strings.Join(lines, "\n") + "\n" + indent + "}"
+	}
+}
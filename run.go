@@ -0,0 +1,145 @@
+package fail
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+var repeatAggregate = false
+
+// SetRepeatAggregate controls how Repeat reports failures. By default Repeat fails on the first failing iteration.
+// If aggregate is true, every iteration is run and all failing iterations are reported together.
+func SetRepeatAggregate(aggregate bool) {
+	repeatAggregate = aggregate
+}
+
+// Repeat runs body n times, recovering failures from each iteration. It panics with a failure including the index of
+// the failing iteration and args. The failure state is reset between iterations. It must be used in conjunction
+// with Using.
+func Repeat(n int, body func(), args ...interface{}) {
+	var failed []interface{}
+	for i := 0; i < n; i++ {
+		res, ok := attempt(body)
+		if !ok {
+			continue
+		}
+		msg := append([]interface{}{fmt.Sprintf("iteration %d of %d failed:", i, n)}, res...)
+		if !repeatAggregate {
+			Now(append(msg, args...)...)
+			return
+		}
+		failed = append(failed, msg...)
+	}
+	if len(failed) > 0 {
+		Now(append(failed, args...)...)
+	}
+}
+
+// FuzzBody runs body, calling t.Fatal if it panics with a failure. It is intended for use inside testing.F.Fuzz
+// targets and testing/quick properties, where a deferred call to Using is not run for each input. The input values
+// are included in the failure message. Panics with a value that is not a failure are propagated.
+//
+//	f.Fuzz(func(t *testing.T, data []byte) {
+//		fail.FuzzBody(t, func() {
+//			_, err := Parse(data)
+//			fail.IfErr(err)
+//		}, data)
+//	})
+func FuzzBody(t *testing.T, body func(), input ...interface{}) {
+	t.Helper()
+	res, ok := attempt(body)
+	if !ok {
+		return
+	}
+	for _, in := range input {
+		res = append(res, fmt.Sprintf("\ninput: %#v", in))
+	}
+	t.Fatal(res...)
+}
+
+// ExpectFail runs body, which is expected to fail. The test passes if body panics with a failure, which is logged
+// using t.Log. If body succeeds, t.Fatal is called to report that the expected failure no longer occurs. Panics with
+// a value that is not a failure are propagated. It is intended for tests of known broken behaviour.
+func ExpectFail(t testing.TB, body func()) {
+	t.Helper()
+	res, ok := attempt(body)
+	if !ok {
+		t.Fatal("expected failure did not occur, remove ExpectFail")
+	}
+	t.Log(append([]interface{}{"expected failure:"}, res...)...)
+}
+
+// Soft runs body, recovering any failure and collecting it instead of stopping the test. Collected failures are
+// reported by the enclosing Using when its scope ends, so the test still fails. It is useful for continuing a loop
+// past a failing iteration. It must be used in conjunction with Using.
+//
+//	for _, c := range cases {
+//		fail.Soft(func() {
+//			fail.If(c.got != c.want, c.got, " != ", c.want)
+//		})
+//	}
+func Soft(body func()) {
+	res, msg, ok := attemptMessages(body)
+	if ok {
+		capture()
+		collect("Soft failure: "+msg, "Soft failure: "+fmt.Sprintln(res...))
+	}
+}
+
+// PanicsIs runs fn and panics if fn does not panic with an error matching target using errors.Is, constructing a
+// failure message stating whether fn did not panic, panicked with a value that is not an error or panicked with a
+// different error, and args. Failures raised by fn are propagated. It must be used in conjunction with Using.
+func PanicsIs(fn func(), target error, args ...interface{}) {
+	r, panicked := catch(fn)
+	if _, ok := r.(failure); ok {
+		panic(r)
+	}
+	err, isErr := r.(error)
+	switch {
+	case !panicked:
+		Now(append([]interface{}{fmt.Sprintf("did not panic, want panic with %v", target)}, args...)...)
+	case !isErr:
+		Now(append([]interface{}{fmt.Sprintf("panicked with non-error %#v, want %v", r, target)}, args...)...)
+	case !errors.Is(err, target):
+		Now(append([]interface{}{fmt.Sprintf("panicked with %v, want %v", renderErr(err), target)}, args...)...)
+	}
+}
+
+// catch runs fn, returning the value it panicked with, if it panicked.
+func catch(fn func()) (r interface{}, panicked bool) {
+	panicked = true
+	defer func() {
+		if panicked {
+			r = recover()
+		}
+	}()
+	fn()
+	panicked = false
+	return nil, false
+}
+
+// attempt runs body, returning the failure it panicked with, if any. Failures collected before body runs are left for
+// the enclosing Using and are not returned.
+func attempt(body func()) (res []interface{}, failed bool) {
+	res, _, failed = attemptMessages(body)
+	return res, failed
+}
+
+// attemptMessages is like attempt, but also returns the failure messages without stack traces, as returned by
+// messages.
+func attemptMessages(body func()) (res []interface{}, msg string, failed bool) {
+	savedQueue, savedSuppressed, savedFailing, savedPushed := queue, suppressed, failing, pushed
+	queue, suppressed, pushed = nil, 0, nil
+	defer func() {
+		r := recover()
+		if f, ok := r.(failure); ok {
+			r = unnamed(f)
+		}
+		msg = messages(r)
+		res, failed = recovered(r)
+		queue, suppressed, failing, pushed = savedQueue, savedSuppressed, savedFailing, savedPushed
+	}()
+	body()
+	return nil, "", false
+}
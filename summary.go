@@ -0,0 +1,82 @@
+package fail
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type summary struct {
+	mu      sync.Mutex
+	names   []string
+	results map[string]bool
+}
+
+func (s *summary) record(name string, passed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.names = append(s.names, name)
+	s.results[name] = passed
+}
+
+func (s *summary) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var lines []string
+	failed := 0
+	for _, name := range s.names {
+		result := "PASS"
+		if !s.results[name] {
+			result, failed = "FAIL", failed+1
+		}
+		lines = append(lines, result+"  "+name)
+	}
+	header := fmt.Sprintf("summary: %d passed, %d failed", len(s.names)-failed, failed)
+	return strings.Join(append([]string{header}, lines...), "\n")
+}
+
+var summariesMu sync.Mutex
+var summaries = map[*testing.T]*summary{}
+
+// Summary tracks the results of subtests of t run using Run. The returned function logs a table of the passed and
+// failed subtests and must be called once they have completed. It does not change whether any test passes or fails.
+//
+//	func TestSomething(t *testing.T) {
+//		defer fail.Summary(t)()
+//		fail.Run(t, "first", func(t *testing.T) {
+//			...
+//		})
+//	}
+//
+// If the subtests call t.Parallel, pass the returned function to t.Cleanup instead of deferring it.
+func Summary(t *testing.T) (done func()) {
+	s := &summary{results: map[string]bool{}}
+	summariesMu.Lock()
+	summaries[t] = s
+	summariesMu.Unlock()
+	return func() {
+		t.Helper()
+		summariesMu.Lock()
+		delete(summaries, t)
+		summariesMu.Unlock()
+		t.Log(s)
+	}
+}
+
+// Run runs body as a subtest of t called name, recovering failures using Using(t.Fatal). If Summary is tracking t,
+// the result of the subtest is recorded. It returns the result of t.Run.
+func Run(t *testing.T, name string, body func(t *testing.T)) bool {
+	summariesMu.Lock()
+	s := summaries[t]
+	summariesMu.Unlock()
+	return t.Run(name, func(t *testing.T) {
+		if s != nil {
+			t.Cleanup(func() {
+				s.record(t.Name(), !t.Failed())
+			})
+		}
+		defer Using(t.Fatal)
+		body(t)
+	})
+}
@@ -0,0 +1,110 @@
+package fail
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IfNotSynced waits for wg and panics if it is not done within timeout, constructing a failure message with the
+// stacks of all goroutines and args, so that the goroutines that are stuck can be identified. The goroutine waiting
+// on wg exits once wg is done, without blocking. It must be used in conjunction with Using.
+func IfNotSynced(wg *sync.WaitGroup, timeout time.Duration, args ...interface{}) {
+	if TimedOut(wg.Wait, timeout) {
+		Now(append([]interface{}{fmt.Sprintf("wait group not done after %v, goroutines:\n%s", timeout, goroutines())}, args...)...)
+	}
+}
+
+// IfLockTimeout acquires and immediately releases lock, panicking if it cannot be acquired within timeout,
+// constructing a failure message indicating a probable deadlock with the stacks of all goroutines and args. If the
+// timeout is reached, lock is still acquired and released in the background once it becomes available. It must be
+// used in conjunction with Using.
+func IfLockTimeout(lock sync.Locker, timeout time.Duration, args ...interface{}) {
+	acquire := func() {
+		lock.Lock()
+		lock.Unlock()
+	}
+	if TimedOut(acquire, timeout) {
+		Now(append([]interface{}{fmt.Sprintf("lock not acquired after %v, probable deadlock, goroutines:\n%s", timeout, goroutines())}, args...)...)
+	}
+}
+
+// IfNotConcurrentSafe runs fn the given number of iterations in each of the given number of goroutines at the same
+// time, and panics if fn panics in any goroutine, constructing a failure message with the first panic value, the
+// stack of the goroutine that panicked and args. It is intended to be run with -race, so that data races in fn are
+// also detected. It must be used in conjunction with Using.
+//
+// fn may use checks such as If and IfErr, which stop the goroutine that fails, but not functions that recover
+// failures, such as Soft and Repeat.
+func IfNotConcurrentSafe(fn func(), goroutines, iterations int, args ...interface{}) {
+	first := stress(goroutines, func(int) {
+		for i := 0; i < iterations; i++ {
+			fn()
+		}
+	})
+	if first != "" {
+		Now(append([]interface{}{first}, args...)...)
+	}
+}
+
+// stressing is the number of calls to stress in progress. While it is non-zero, Now panics without changing the
+// failure state, which is not safe for concurrent use.
+var stressing int32
+
+// stress calls fn with each index from 0 to goroutines-1, each in its own goroutine and all at the same time, and
+// returns a description of the first panic in any goroutine, with the stack of that goroutine, or "" if there was
+// none.
+func stress(goroutines int, fn func(g int)) string {
+	atomic.AddInt32(&stressing, 1)
+	defer atomic.AddInt32(&stressing, -1)
+	var mu sync.Mutex
+	var first string
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					if f, ok := r.(failure); ok {
+						r = strings.TrimSpace(fmt.Sprintln(f...))
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					if first == "" {
+						first = fmt.Sprintf("goroutine %d panicked: %v%s", g, r, strings.Join(SquashStack(strings.Split(string(debug.Stack()), "\n")), "\n"))
+					}
+				}
+			}()
+			<-start
+			fn(g)
+		}(g)
+	}
+	close(start)
+	wg.Wait()
+	return first
+}
+
+// ConcurrentThen calls mutate with each index from 0 to goroutines-1, each in its own goroutine and all at the same
+// time, waits for them to finish and then calls validate, panicking if it returns an error. If mutate panics in any
+// goroutine, it panics with the first panic value and the stack of the goroutine that panicked instead, without
+// calling validate. Failure messages include args. It is intended to be run with -race, to check that concurrent
+// mutations of a data structure leave it in a valid state. It must be used in conjunction with Using.
+//
+// As with IfNotConcurrentSafe, mutate may use checks such as If, but not functions that recover failures.
+//
+//	fail.ConcurrentThen(8, func(i int) { m.Store(i, i) }, func() error { return checkLen(m, 8) })
+func ConcurrentThen(goroutines int, mutate func(i int), validate func() error, args ...interface{}) {
+	first := stress(goroutines, mutate)
+	if first != "" {
+		Now(append([]interface{}{first}, args...)...)
+		return
+	}
+	if err := validate(); err != nil {
+		Now(append([]interface{}{fmt.Sprintf("invalid state after %d concurrent mutations: %v", goroutines, renderErr(err))}, args...)...)
+	}
+}
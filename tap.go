@@ -0,0 +1,34 @@
+package fail
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// UsingTAP recovers from panics like Using, but reports the result as a TAP test line written to w instead of calling
+// a failer. If a failure is recovered, it writes "not ok testNum - message" followed by a YAML diagnostic block with
+// the full message and stack. Otherwise it writes "ok testNum". It must be used as part of a deferred call.
+func UsingTAP(w io.Writer, testNum int) {
+	r := recover()
+	f, _ := r.(failure)
+	res, ok := settle(r)
+	if !ok {
+		fmt.Fprintf(w, "ok %d\n", testNum)
+		return
+	}
+	msg := strings.TrimSpace(fmt.Sprintln(f...))
+	if len(f) == 0 {
+		msg = "collected failures"
+	}
+	var details []string
+	for _, part := range res[len(f):] {
+		details = append(details, strings.Split(strings.TrimSpace(fmt.Sprint(part)), "\n")...)
+	}
+	fmt.Fprintf(w, "not ok %d - %s\n", testNum, strings.SplitN(msg, "\n", 2)[0])
+	fmt.Fprintf(w, "  ---\n  message: %q\n  stack: |\n", msg)
+	for _, line := range details {
+		fmt.Fprintf(w, "    %s\n", line)
+	}
+	fmt.Fprintf(w, "  ...\n")
+}
@@ -0,0 +1,159 @@
+package fail
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+var observer func(op string, d time.Duration)
+
+// SetVerbose logs the time taken by slow operations like TimedOut and IfSlowP using t.Logf, so that they are visible
+// when tests are run with -v. Logging stops when the test completes or when SetVerbose is called with nil.
+func SetVerbose(t testing.TB) {
+	if t == nil {
+		observer = nil
+		return
+	}
+	observer = func(op string, d time.Duration) {
+		t.Logf("fail: %s took %v", op, d)
+	}
+	t.Cleanup(func() {
+		observer = nil
+	})
+}
+
+// observe reports the time since start for op, if SetVerbose is in effect.
+func observe(op string, start time.Time) {
+	if observer != nil {
+		observer(op, time.Since(start))
+	}
+}
+
+// Deadline starts a timer that aborts the test binary if the returned cancel function is not called within d. The
+// panic reports the name of the test, its time budget and the stacks of all goroutines. It is intended to catch
+// hangs in a single test more precisely than the -timeout flag.
+//
+//	func TestSomething(t *testing.T) {
+//		defer fail.Deadline(t, 5*time.Second)()
+//		...
+//	}
+func Deadline(t testing.TB, d time.Duration) (cancel func()) {
+	name := t.Name()
+	timer := time.AfterFunc(d, func() {
+		panic(fmt.Sprintf("%s exceeded its time budget of %v\n%s", name, d, goroutines()))
+	})
+	return func() {
+		timer.Stop()
+	}
+}
+
+// IfSlowP runs fn the given number of times and panics if the requested percentile of the run durations exceeds
+// limit, constructing a failure message with the measured duration and args. percentile is in the range (0, 100],
+// so IfSlowP(fn, 100, 99, limit) checks the 99th percentile. It must be used in conjunction with Using.
+//
+// IfSlowP is a coarse latency check and is not a substitute for a benchmark.
+func IfSlowP(fn func(), runs int, percentile float64, limit time.Duration, args ...interface{}) {
+	if runs < 1 || percentile <= 0 || percentile > 100 {
+		Now(append([]interface{}{fmt.Sprintf("IfSlowP: invalid runs %d or percentile %v", runs, percentile)}, args...)...)
+		return
+	}
+	defer observe("IfSlowP", time.Now())
+	durations := make([]time.Duration, runs)
+	for i := range durations {
+		start := time.Now()
+		fn()
+		durations[i] = time.Since(start)
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	if p := durations[int(math.Ceil(percentile/100*float64(runs)))-1]; p > limit {
+		Now(append([]interface{}{fmt.Sprintf("p%v of %d runs took %v, limit is %v", percentile, runs, p, limit)}, args...)...)
+	}
+}
+
+// IfBenchSlow runs fn the given number of times and panics if the average duration of a run exceeds limit,
+// constructing a failure message with the measured average and args. It must be used in conjunction with Using.
+//
+// Timings in tests are noisy, so IfBenchSlow is only suitable as a coarse guard against large regressions with a
+// generous limit. It is not a replacement for a benchmark.
+func IfBenchSlow(fn func(), runs int, limit time.Duration, args ...interface{}) {
+	if runs < 1 {
+		Now(append([]interface{}{fmt.Sprintf("IfBenchSlow: invalid runs %d", runs)}, args...)...)
+		return
+	}
+	start := time.Now()
+	defer observe("IfBenchSlow", start)
+	for i := 0; i < runs; i++ {
+		fn()
+	}
+	if avg := time.Since(start) / time.Duration(runs); avg > limit {
+		Now(append([]interface{}{fmt.Sprintf("average of %d runs took %v, limit is %v", runs, avg, limit)}, args...)...)
+	}
+}
+
+// IfErrOrSlow runs fn and panics if it returns an error or takes longer than limit, constructing a failure message
+// stating which of the two happened, with the elapsed time, and args. Unlike TimedOut, fn always runs to completion
+// and its actual duration is compared with limit. An error is reported in preference to slowness. It must be used in
+// conjunction with Using.
+func IfErrOrSlow(fn func() error, limit time.Duration, args ...interface{}) {
+	start := time.Now()
+	defer observe("IfErrOrSlow", start)
+	err := fn()
+	elapsed := time.Since(start)
+	switch {
+	case err != nil:
+		Now(append([]interface{}{fmt.Sprintf("failed after %v: %v", elapsed, renderErr(err))}, args...)...)
+	case elapsed > limit:
+		Now(append([]interface{}{fmt.Sprintf("succeeded but took %v, limit is %v", elapsed, limit)}, args...)...)
+	}
+}
+
+// RecentSkew is the amount by which a time passed to IfNotRecent may be in the future, to allow for clock skew.
+const RecentSkew = time.Second
+
+// IfNotRecent panics if ts is more than within before now, or more than RecentSkew after now, constructing a failure
+// message with the difference between ts and now and args. It must be used in conjunction with Using.
+func IfNotRecent(ts time.Time, within time.Duration, args ...interface{}) {
+	switch delta := time.Since(ts); {
+	case delta > within:
+		Now(append([]interface{}{fmt.Sprintf("%v is %v ago, more than %v", ts, delta, within)}, args...)...)
+	case -delta > RecentSkew:
+		Now(append([]interface{}{fmt.Sprintf("%v is %v in the future", ts, -delta)}, args...)...)
+	}
+}
+
+// IfDurNotClose panics if got and want differ by more than tol, constructing a failure message with all three
+// durations and args. It must be used in conjunction with Using.
+func IfDurNotClose(got, want, tol time.Duration, args ...interface{}) {
+	diff := got - want
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > tol {
+		Now(append([]interface{}{fmt.Sprintf("%v is not within %v of %v", got, tol, want)}, args...)...)
+	}
+}
+
+// IfTimeNotEqual panics if got and want do not represent the same instant, constructing a failure message with both
+// times, including their locations, and args. Times are compared using time.Time.Equal, so monotonic clock readings
+// and locations are ignored. It must be used in conjunction with Using.
+func IfTimeNotEqual(got, want time.Time, args ...interface{}) {
+	if !got.Equal(want) {
+		Now(append([]interface{}{fmt.Sprintf("%s (%v) != %s (%v)", got.Format(time.RFC3339Nano), got.Location(), want.Format(time.RFC3339Nano), want.Location())}, args...)...)
+	}
+}
+
+// goroutines returns the stacks of all running goroutines.
+func goroutines() string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,355 @@
+package fail
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// IfField panics if the field of v named by field is not reflect.DeepEqual to want, constructing a failure message
+// with both values and args. Nested fields can be accessed using a dotted path such as "Config.Port". Pointers are
+// followed along the path. It also panics if a field does not exist or is not exported. It must be used in
+// conjunction with Using.
+func IfField(v interface{}, field string, want interface{}, args ...interface{}) {
+	val := reflect.ValueOf(v)
+	for _, name := range strings.Split(field, ".") {
+		for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+			if val.IsNil() {
+				break
+			}
+			val = val.Elem()
+		}
+		if val.Kind() != reflect.Struct {
+			Now(append([]interface{}{fmt.Sprintf("cannot read %s of %s: %s is not a struct", field, typeOf(v), describe(val))}, args...)...)
+			return
+		}
+		sf, ok := val.Type().FieldByName(name)
+		if !ok {
+			Now(append([]interface{}{fmt.Sprintf("%s has no field %s", val.Type(), name)}, args...)...)
+			return
+		}
+		if sf.PkgPath != "" {
+			Now(append([]interface{}{fmt.Sprintf("field %s of %s is not exported", name, val.Type())}, args...)...)
+			return
+		}
+		fv, err := val.FieldByIndexErr(sf.Index)
+		if err != nil {
+			Now(append([]interface{}{fmt.Sprintf("cannot read %s of %s: embedded %s is nil", field, typeOf(v), nilEmbed(val, sf.Index))}, args...)...)
+			return
+		}
+		val = fv
+	}
+	if got := val.Interface(); !reflect.DeepEqual(got, want) {
+		Now(append([]interface{}{fmt.Sprintf("%s: %#v != %#v", field, got, want)}, args...)...)
+	}
+}
+
+// nilEmbed returns the dotted path of the first nil embedded pointer along index in the struct val.
+func nilEmbed(val reflect.Value, index []int) string {
+	var path []string
+	for _, i := range index {
+		if val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				break
+			}
+			val = val.Elem()
+		}
+		path = append(path, val.Type().Field(i).Name)
+		val = val.Field(i)
+	}
+	return strings.Join(path, ".")
+}
+
+func describe(val reflect.Value) string {
+	switch {
+	case !val.IsValid():
+		return "nil"
+	case (val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface) && val.IsNil():
+		return "nil " + val.Type().String()
+	default:
+		return val.Type().String()
+	}
+}
+
+func typeOf(v interface{}) string {
+	if v == nil {
+		return "nil"
+	}
+	return reflect.TypeOf(v).String()
+}
+
+// diff describes the first difference between got and want, or returns "" if they are reflect.DeepEqual. The
+// description starts with the path to the differing value, such as .Items[2].Name.
+func diff(got, want interface{}) string {
+	return valueDiff("", reflect.ValueOf(got), reflect.ValueOf(want), map[visit]bool{})
+}
+
+// visit records a pair of references compared by valueDiff, so that cyclic values are compared in finite time, as in
+// reflect.DeepEqual.
+type visit struct {
+	got, want uintptr
+	typ       reflect.Type
+}
+
+func valueDiff(path string, got, want reflect.Value, visited map[visit]bool) string {
+	differ := func() string {
+		p := path
+		if p == "" {
+			p = "value"
+		}
+		return fmt.Sprintf("%s: %s != %s", p, show(got), show(want))
+	}
+	if !got.IsValid() || !want.IsValid() {
+		if got.IsValid() == want.IsValid() {
+			return ""
+		}
+		return differ()
+	}
+	if got.Type() != want.Type() {
+		return fmt.Sprintf("%s: %s != %s", strings.TrimPrefix(path+" type", " "), got.Type(), want.Type())
+	}
+	switch got.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if !got.IsNil() && !want.IsNil() {
+			v := visit{got.Pointer(), want.Pointer(), got.Type()}
+			if visited[v] {
+				return ""
+			}
+			visited[v] = true
+		}
+	}
+	switch got.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if got.IsNil() || want.IsNil() {
+			if got.IsNil() == want.IsNil() {
+				return ""
+			}
+			return differ()
+		}
+		if got.Kind() == reflect.Ptr && got.Pointer() == want.Pointer() {
+			return ""
+		}
+		return valueDiff(path, got.Elem(), want.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < got.NumField(); i++ {
+			if d := valueDiff(path+"."+got.Type().Field(i).Name, got.Field(i), want.Field(i), visited); d != "" {
+				return d
+			}
+		}
+		return ""
+	case reflect.Slice, reflect.Array:
+		if got.Kind() == reflect.Slice && got.IsNil() != want.IsNil() {
+			return differ()
+		}
+		for i := 0; i < got.Len() && i < want.Len(); i++ {
+			if d := valueDiff(fmt.Sprintf("%s[%d]", path, i), got.Index(i), want.Index(i), visited); d != "" {
+				return d
+			}
+		}
+		if got.Len() != want.Len() {
+			return fmt.Sprintf("%s: length %d != %d", strings.TrimPrefix(path+" length", " "), got.Len(), want.Len())
+		}
+		return ""
+	case reflect.Map:
+		if got.IsNil() != want.IsNil() {
+			return differ()
+		}
+		keys := append(got.MapKeys(), want.MapKeys()...)
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			if d := valueDiff(fmt.Sprintf("%s[%#v]", path, k), got.MapIndex(k), want.MapIndex(k), visited); d != "" {
+				return d
+			}
+		}
+		return ""
+	case reflect.Func:
+		if got.IsNil() && want.IsNil() {
+			return ""
+		}
+		return differ()
+	case reflect.Bool:
+		if got.Bool() == want.Bool() {
+			return ""
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if got.Int() == want.Int() {
+			return ""
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if got.Uint() == want.Uint() {
+			return ""
+		}
+	case reflect.Float32, reflect.Float64:
+		if got.Float() == want.Float() {
+			return ""
+		}
+	case reflect.Complex64, reflect.Complex128:
+		if got.Complex() == want.Complex() {
+			return ""
+		}
+	case reflect.String:
+		if got.String() == want.String() {
+			return ""
+		}
+	case reflect.Chan, reflect.UnsafePointer:
+		if got.Pointer() == want.Pointer() {
+			return ""
+		}
+	}
+	return differ()
+}
+
+func show(v reflect.Value) string {
+	if !v.IsValid() {
+		return "<missing>"
+	}
+	return fmt.Sprintf("%#v", v)
+}
+
+// copied identifies a pointer or map copied by deepCopy. The type is needed because a struct and its first field
+// have the same address.
+type copied struct {
+	p uintptr
+	t reflect.Type
+}
+
+// deepCopy returns a deep copy of v. Unexported struct fields are copied shallowly, since they cannot be set using
+// reflection.
+func deepCopy(v reflect.Value, seen map[copied]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		key := copied{v.Pointer(), v.Type()}
+		if c, ok := seen[key]; ok {
+			return c
+		}
+		c := reflect.New(v.Type().Elem())
+		seen[key] = c
+		c.Elem().Set(deepCopy(v.Elem(), seen))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem(), seen))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i), seen))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i), seen))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		key := copied{v.Pointer(), v.Type()}
+		if c, ok := seen[key]; ok {
+			return c
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		seen[key] = c
+		for _, k := range v.MapKeys() {
+			c.SetMapIndex(k, deepCopy(v.MapIndex(k), seen))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopy(v.Field(i), seen))
+			}
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// IfNotImplements panics if the type of v does not implement the interface type pointed to by iface, constructing a
+// failure message naming both types, listing the missing methods, and args. iface must be a nil pointer to an
+// interface type, such as (*io.Reader)(nil). It must be used in conjunction with Using.
+func IfNotImplements(v interface{}, iface interface{}, args ...interface{}) {
+	it := reflect.TypeOf(iface)
+	if it == nil || it.Kind() != reflect.Ptr || it.Elem().Kind() != reflect.Interface {
+		Now(append([]interface{}{fmt.Sprintf("%s is not a pointer to an interface", typeOf(iface))}, args...)...)
+		return
+	}
+	it = it.Elem()
+	vt := reflect.TypeOf(v)
+	if vt == nil {
+		Now(append([]interface{}{fmt.Sprintf("nil does not implement %s", it)}, args...)...)
+		return
+	}
+	if vt.Implements(it) {
+		return
+	}
+	var missing []string
+	for i := 0; i < it.NumMethod(); i++ {
+		want := it.Method(i)
+		m, ok := vt.MethodByName(want.Name)
+		switch {
+		case !ok:
+			missing = append(missing, want.Name+strings.TrimPrefix(want.Type.String(), "func"))
+		case withoutReceiver(m.Type) != want.Type:
+			has := strings.TrimPrefix(withoutReceiver(m.Type).String(), "func")
+			missing = append(missing, fmt.Sprintf("%s%s (has %s%s)", want.Name, strings.TrimPrefix(want.Type.String(), "func"), want.Name, has))
+		}
+	}
+	Now(append([]interface{}{fmt.Sprintf("%s does not implement %s, missing methods: %s", vt, it, strings.Join(missing, ", "))}, args...)...)
+}
+
+// withoutReceiver returns the type of method without its receiver argument.
+func withoutReceiver(method reflect.Type) reflect.Type {
+	in := make([]reflect.Type, method.NumIn()-1)
+	for i := range in {
+		in[i] = method.In(i + 1)
+	}
+	out := make([]reflect.Type, method.NumOut())
+	for i := range out {
+		out[i] = method.Out(i)
+	}
+	return reflect.FuncOf(in, out, method.IsVariadic())
+}
+
+// IfLenDelta panics if the length of after minus the length of before is not delta, constructing a failure message
+// with both lengths, delta and args. before and after must be arrays, channels, maps, slices or strings, and it
+// panics if either is not. It must be used in conjunction with Using.
+func IfLenDelta(before, after interface{}, delta int, args ...interface{}) {
+	b, ok := length(before)
+	if !ok {
+		Now(append([]interface{}{fmt.Sprintf("cannot take the length of %s", typeOf(before))}, args...)...)
+		return
+	}
+	a, ok := length(after)
+	if !ok {
+		Now(append([]interface{}{fmt.Sprintf("cannot take the length of %s", typeOf(after))}, args...)...)
+		return
+	}
+	if a-b != delta {
+		Now(append([]interface{}{fmt.Sprintf("length changed from %d to %d (by %d), want change of %d", b, a, a-b, delta)}, args...)...)
+	}
+}
+
+func length(v interface{}) (int, bool) {
+	switch val := reflect.ValueOf(v); val.Kind() {
+	case reflect.Array, reflect.Chan, reflect.Map, reflect.Slice, reflect.String:
+		return val.Len(), true
+	default:
+		return 0, false
+	}
+}